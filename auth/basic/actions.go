@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"strings"
+	"sync"
+)
+
+// ActionSet holds controller actions, in the form of "ControllerName.ActionName" or "ControllerName.*"
+// for every action of the controller, optionally with values such as required scopes.
+// The zero value is an empty set, safe to use concurrently with requests being served.
+type ActionSet struct {
+	lock    sync.RWMutex
+	actions map[string][]string
+}
+
+// Add adds the action to the set, along with the values.
+func (as *ActionSet) Add(action string, values ...string) {
+	as.lock.Lock()
+	defer as.lock.Unlock()
+
+	if as.actions == nil {
+		as.actions = map[string][]string{}
+	}
+	as.actions[action] = append(as.actions[action], values...)
+}
+
+// Contains returns true if the action, or every action of its controller, is in the set.
+func (as *ActionSet) Contains(action string) bool {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	if _, found := as.actions[action]; found {
+		return true
+	}
+	if i := strings.Index(action, "."); i != -1 {
+		_, found := as.actions[action[:i]+".*"]
+		return found
+	}
+	return false
+}
+
+// Values returns the values added for the action and for every action of its controller.
+func (as *ActionSet) Values(action string) []string {
+	as.lock.RLock()
+	defer as.lock.RUnlock()
+
+	values := as.actions[action]
+	if i := strings.Index(action, "."); i != -1 {
+		values = append(append([]string{}, values...), as.actions[action[:i]+".*"]...)
+	}
+	return values
+}
@@ -0,0 +1,37 @@
+package auth_test
+
+import (
+	"reflect"
+	"testing"
+
+	auth "github.com/revel/modules/auth/basic"
+)
+
+func TestActionSet(t *testing.T) {
+	var actions auth.ActionSet
+	if actions.Contains("App.Index") {
+		t.Fatal("An empty set should not contain actions")
+	}
+
+	actions.Add("App.Index")
+	actions.Add("Orders.*", "orders:read")
+	actions.Add("Orders.Create", "orders:write")
+	for action, contained := range map[string]bool{
+		"App.Index":     true,
+		"App.Other":     false,
+		"Orders.Create": true,
+		"Orders.List":   true,
+		"Index":         false,
+	} {
+		if actions.Contains(action) != contained {
+			t.Errorf("Contains(%q) should be %v", action, contained)
+		}
+	}
+
+	if values := actions.Values("Orders.Create"); !reflect.DeepEqual(values, []string{"orders:write", "orders:read"}) {
+		t.Errorf("Should have returned the values of the action and its controller, got %v", values)
+	}
+	if values := actions.Values("App.Index"); len(values) != 0 {
+		t.Errorf("Should have returned no values, got %v", values)
+	}
+}
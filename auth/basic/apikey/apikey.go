@@ -0,0 +1,213 @@
+// Package apikey authenticates machine clients with long-lived API keys.
+//
+// A key looks like "rvl_3mfq7hxk2a9c_<secret>". Its prefix, "rvl_3mfq7hxk2a9c", identifies it and may be shown
+// in listings and logs, the whole key is only known by the client: the auth.Store keeps an HMAC-SHA256 of it.
+// The random secret is too long to be guessed, so a fast hash is enough, unlike the slow password hashes.
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+)
+
+var (
+	ErrKeyNotFound = errors.New("apikey: key not found")
+	ErrInvalidKey  = errors.New("apikey: invalid key")
+	ErrRevokedKey  = errors.New("apikey: revoked key")
+)
+
+// lastUsedPrecision limits the writes recording the use of a key.
+const lastUsedPrecision = time.Minute
+
+// hashPrefix starts the hashes of the keys, naming the algorithm.
+const hashPrefix = "$hmac-sha256$"
+
+// HashKey keys the hashes of the API keys, it is set from the `auth.apikey.key` or `app.secret` setting at startup.
+// Changing it invalidates every key.
+var HashKey []byte
+
+var (
+	keyPrefix = "rvl"
+	header    = "X-API-Key"
+	param     = ""
+)
+
+func init() {
+	revel.OnAppStart(func() {
+		keyPrefix = revel.Config.StringDefault("auth.apikey.prefix", keyPrefix)
+		header = revel.Config.StringDefault("auth.apikey.header", header)
+		param = revel.Config.StringDefault("auth.apikey.param", param)
+		HashKey = []byte(revel.Config.StringDefault("auth.apikey.key", revel.Config.StringDefault("app.secret", "")))
+	})
+}
+
+// UsageStore is implemented by a StorageDriver able to record the use of a key without saving it whole,
+// so that a key revoked meanwhile stays revoked. Authenticate only records the use of keys with a UsageStore.
+type UsageStore interface {
+	// UseKey sets the LastUsed time of the key with the prefix, unless the key is revoked.
+	UseKey(prefix string, at time.Time) error
+}
+
+// Key is an API key as kept by the auth.Store, which must support it in Save and Load.
+// Load is given a Key holding the Prefix only, and returns ErrKeyNotFound when there is no such key.
+type Key struct {
+	Prefix    string // identifies the key
+	Hash      string // HMAC-SHA256 of the whole key
+	UserId    string // owner of the key
+	Name      string // given by the owner, e.g. "CI deploy"
+	Scopes    []string
+	CreatedAt time.Time
+	LastUsed  time.Time // zero until used
+	RevokedAt time.Time // zero unless revoked
+}
+
+// HasScope returns true if the key was granted the scope.
+func (k *Key) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked returns true if the key may no longer be used.
+func (k *Key) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// Generate creates a key for the user and saves it through the auth.Store.
+// The returned plain key must be shown to the user right away, it cannot be found again.
+func Generate(userId, name string, scopes ...string) (plain string, key *Key, err error) {
+	if auth.Store == nil {
+		return "", nil, errors.New("auth module StorageDriver not set")
+	}
+	if len(HashKey) == 0 {
+		return "", nil, errNoHashKey
+	}
+
+	for {
+		id, err := randomString(12)
+		if err != nil {
+			return "", nil, err
+		}
+		key = &Key{Prefix: keyPrefix + "_" + id}
+		if err = auth.Store.Load(key); err == ErrKeyNotFound {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	random, err := randomString(32)
+	if err != nil {
+		return "", nil, err
+	}
+	plain = key.Prefix + "_" + random
+
+	key.Hash = hashKey(plain)
+	key.UserId = userId
+	key.Name = name
+	key.Scopes = scopes
+	key.CreatedAt = time.Now()
+
+	if err = auth.Store.Save(key); err != nil {
+		return "", nil, err
+	}
+	return plain, key, nil
+}
+
+// Authenticate returns the key matching a plain key and records its use.
+func Authenticate(plain string) (*Key, error) {
+	if auth.Store == nil {
+		return nil, errors.New("auth module StorageDriver not set")
+	}
+	if len(HashKey) == 0 {
+		return nil, errNoHashKey
+	}
+
+	i := strings.LastIndex(plain, "_")
+	if i <= 0 {
+		return nil, ErrInvalidKey
+	}
+	key := &Key{Prefix: plain[:i]}
+	if err := auth.Store.Load(key); err != nil {
+		if err == ErrKeyNotFound {
+			return nil, ErrInvalidKey
+		}
+		return nil, err
+	}
+
+	if !hmac.Equal([]byte(key.Hash), []byte(hashKey(plain))) {
+		return nil, ErrInvalidKey
+	}
+	if key.Revoked() {
+		return nil, ErrRevokedKey
+	}
+
+	if now := time.Now(); now.Sub(key.LastUsed) >= lastUsedPrecision {
+		if err := recordUse(key, now); err != nil {
+			revel.AppLog.Warn("Failed to record the use of an API key", "key", key.Prefix, "error", err)
+		}
+	}
+	return key, nil
+}
+
+// recordUse sets the LastUsed time of the key through the UsageStore, when the auth.Store is one.
+func recordUse(key *Key, at time.Time) error {
+	usage, ok := auth.Store.(UsageStore)
+	if !ok {
+		return nil
+	}
+	if err := usage.UseKey(key.Prefix, at); err != nil {
+		return err
+	}
+	key.LastUsed = at
+	return nil
+}
+
+// Revoke stops the key with the given prefix from working.
+func Revoke(prefix string) error {
+	if auth.Store == nil {
+		return errors.New("auth module StorageDriver not set")
+	}
+
+	key := &Key{Prefix: prefix}
+	if err := auth.Store.Load(key); err != nil {
+		return err
+	}
+	if key.Revoked() {
+		return nil
+	}
+	key.RevokedAt = time.Now()
+	return auth.Store.Save(key)
+}
+
+var errNoHashKey = errors.New("apikey: no hash key, set auth.apikey.key or app.secret")
+
+// hashKey returns the HMAC-SHA256 of the plain key with the HashKey.
+func hashKey(plain string) string {
+	mac := hmac.New(sha256.New, HashKey)
+	mac.Write([]byte(plain))
+	return hashPrefix + base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// randomString returns length random lower case base32 characters.
+func randomString(length int) (string, error) {
+	random := make([]byte, length*5/8+1)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(random))[:length], nil
+}
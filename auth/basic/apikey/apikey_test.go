@@ -0,0 +1,111 @@
+package apikey_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/apikey"
+	memoryauth "github.com/revel/modules/auth/basic/driver/storage/memory"
+	"github.com/revel/revel"
+	"github.com/revel/revel/logger"
+)
+
+func init() {
+	apikey.HashKey = []byte("test key")
+}
+
+func TestKeys(t *testing.T) {
+	auth.Store = memoryauth.NewMemoryAuthDriver()
+
+	plain, key, err := apikey.Generate("demo@domain.com", "CI deploy", "orders:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(plain, key.Prefix+"_") || !strings.HasPrefix(key.Prefix, "rvl_") || strings.Contains(key.Hash, plain) {
+		t.Fatalf("Unexpected key %q with prefix %q and hash %q", plain, key.Prefix, key.Hash)
+	}
+
+	authenticated, err := apikey.Authenticate(plain)
+	if err != nil || authenticated.UserId != "demo@domain.com" || !authenticated.HasScope("orders:read") {
+		t.Fatalf("Should have authenticated the key, got %#v %v", authenticated, err)
+	}
+	if authenticated.LastUsed.IsZero() {
+		t.Error("Should have recorded the use of the key")
+	}
+	if _, err := apikey.Authenticate(plain + "x"); err != apikey.ErrInvalidKey {
+		t.Errorf("Should have refused a wrong key, got %v", err)
+	}
+	if _, err := apikey.Authenticate("rvl_unknown_key"); err != apikey.ErrInvalidKey {
+		t.Errorf("Should have refused an unknown key, got %v", err)
+	}
+
+	// the hash depends on the HashKey
+	apikey.HashKey = []byte("other key")
+	if _, err := apikey.Authenticate(plain); err != apikey.ErrInvalidKey {
+		t.Errorf("Should have refused the key hashed with another HashKey, got %v", err)
+	}
+	apikey.HashKey = []byte("test key")
+
+	if err := apikey.Revoke(key.Prefix); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := apikey.Authenticate(plain); err != apikey.ErrRevokedKey {
+		t.Errorf("Should have refused a revoked key, got %v", err)
+	}
+}
+
+func testKeyFilter(t *testing.T, keyAuth *apikey.KeyAuth, action, plain string) *revel.Controller {
+	t.Helper()
+	r, _ := http.NewRequest("GET", "http://www.example.com/orders", nil)
+	if plain != "" {
+		r.Header.Set("X-API-Key", plain)
+	}
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(r)
+	context.Response.SetResponse(httptest.NewRecorder())
+	c := revel.NewController(context)
+	c.Log = logger.New("module", "test")
+	c.Action = action
+
+	filters := []revel.Filter{
+		keyAuth.KeyFilter,
+		func(c *revel.Controller, fc []revel.Filter) {
+			c.Result = c.RenderText("OK.")
+		},
+	}
+	filters[0](c, filters[1:])
+	return c
+}
+
+func TestKeyFilter(t *testing.T) {
+	auth.Store = memoryauth.NewMemoryAuthDriver()
+	plain, _, err := apikey.Generate("demo@domain.com", "reader", "orders:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyAuth := apikey.NewKeyAuth()
+	keyAuth.RequireScopes("Orders.*", "orders:read")
+	keyAuth.RequireScopes("Orders.Create", "orders:write")
+	keyAuth.Skip("Status.*")
+
+	c := testKeyFilter(t, keyAuth, "Orders.List", plain)
+	if key := apikey.CurrentKey(c); key == nil || key.UserId != "demo@domain.com" {
+		t.Fatalf("Should have authenticated the key, got %d %#v", c.Response.Status, c.Result)
+	}
+	if c = testKeyFilter(t, keyAuth, "Orders.Create", plain); c.Response.Status != http.StatusForbidden {
+		t.Errorf("Should have refused a key missing a scope, got %d", c.Response.Status)
+	}
+	if c = testKeyFilter(t, keyAuth, "Orders.List", ""); c.Response.Status != http.StatusUnauthorized {
+		t.Errorf("Should have refused a request without key, got %d", c.Response.Status)
+	}
+	if c = testKeyFilter(t, keyAuth, "Orders.List", "rvl_unknown_key"); c.Response.Status != http.StatusUnauthorized {
+		t.Errorf("Should have refused an invalid key, got %d", c.Response.Status)
+	}
+	if c = testKeyFilter(t, keyAuth, "Status.Index", ""); apikey.CurrentKey(c) != nil || c.Response.Status != 0 && c.Response.Status != http.StatusOK {
+		t.Errorf("Should have let skipped actions through, got %d", c.Response.Status)
+	}
+}
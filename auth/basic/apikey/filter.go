@@ -0,0 +1,91 @@
+package apikey
+
+import (
+	"errors"
+	"net/http"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+)
+
+// KeyArgKey is the controller Args key holding the authenticated *Key.
+const KeyArgKey = "auth.apikey"
+
+// KeyAuth authenticates requests sending an API key in the `auth.apikey.header` header,
+// or in the `auth.apikey.param` query parameter when set.
+//
+// Usage:
+//  1. Set auth.Store to a StorageDriver supporting *apikey.Key.
+//  2. Create the module and declare the scopes required by actions:
+//     keyAuth := apikey.NewKeyAuth()
+//     keyAuth.RequireScopes("Orders.Create", "orders:write")
+//  3. Add `keyAuth.KeyFilter` to the app's filters (it must come after the revel.RouterFilter).
+type KeyAuth struct {
+	skip   auth.ActionSet
+	scopes auth.ActionSet
+}
+
+// NewKeyAuth is the constructor for KeyAuth.
+func NewKeyAuth() *KeyAuth {
+	return &KeyAuth{}
+}
+
+// Skip excludes actions from authentication, in the form of "ControllerName.ActionName" or "ControllerName.*".
+func (ka *KeyAuth) Skip(actions ...string) {
+	for _, action := range actions {
+		ka.skip.Add(action)
+	}
+}
+
+// RequireScopes refuses keys without all the scopes for the action,
+// in the form of "ControllerName.ActionName" or "ControllerName.*".
+func (ka *KeyAuth) RequireScopes(action string, scopes ...string) {
+	ka.scopes.Add(action, scopes...)
+}
+
+// KeyFilter authenticates the request and places the key in `c.Args[apikey.KeyArgKey]`.
+// Requests without a valid key are answered with 401 Unauthorized,
+// keys missing a scope required by the action with 403 Forbidden.
+func (ka *KeyAuth) KeyFilter(c *revel.Controller, fc []revel.Filter) {
+	if ka.skip.Contains(c.Action) {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	plain := c.Request.GetHttpHeader(header)
+	if plain == "" && param != "" {
+		plain = c.Request.GetQuery().Get(param)
+	}
+	if plain == "" {
+		c.Result = unauthorized(c, "API key required")
+		return
+	}
+
+	key, err := Authenticate(plain)
+	if err != nil {
+		c.Log.Warn("API key authentication failed", "error", err)
+		c.Result = unauthorized(c, "Invalid API key")
+		return
+	}
+	for _, scope := range ka.scopes.Values(c.Action) {
+		if !key.HasScope(scope) {
+			c.Log.Warn("API key missing scope", "key", key.Prefix, "scope", scope)
+			c.Result = c.Forbidden("API key missing scope %s", scope)
+			return
+		}
+	}
+
+	c.Args[KeyArgKey] = key
+	fc[0](c, fc[1:])
+}
+
+// CurrentKey returns the key authenticated for the request, or nil.
+func CurrentKey(c *revel.Controller) *Key {
+	key, _ := c.Args[KeyArgKey].(*Key)
+	return key
+}
+
+func unauthorized(c *revel.Controller, message string) revel.Result {
+	c.Response.Status = http.StatusUnauthorized
+	return c.RenderError(errors.New("401: " + message))
+}
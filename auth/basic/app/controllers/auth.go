@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/twofactor"
+	"github.com/revel/revel"
+)
+
+// returnToKey is the session key keeping the return to URL while the second factor is pending.
+const returnToKey = "auth_return_to"
+
+// Auth logs users in and out of the Revel session.
+// The app renders the login form, posting `username`, `password` and `returnTo` to Auth.Login.
+type Auth struct {
+	*revel.Controller
+}
+
+func init() {
+	auth.Public("Auth.Login", "Auth.TwoFactor")
+	twofactor.Allow("Auth.TwoFactor", "Auth.Logout")
+}
+
+// Login authenticates the user with the auth.Store and the user's SecretDriver, throttled by auth.Login.
+// On success the user is logged in the session and redirected to returnTo, or to the 2FA page
+// when the user has a second factor. On failure the user goes back to the login page with a flash error.
+func (c Auth) Login(username, password, returnTo string) revel.Result {
+	if auth.NewUser == nil || auth.Store == nil {
+		return c.RenderError(errors.New("auth module NewUser or StorageDriver not set"))
+	}
+
+	user := auth.NewUser(username, password)
+	err := auth.Store.Load(user)
+	switch err {
+	case nil:
+		_, err = auth.Login(user, c.ClientIP)
+	case auth.ErrUserNotFound:
+		err = auth.LoginUnknown(user, c.ClientIP)
+	}
+	if err != nil {
+		c.Log.Warn("Login failed", "user", username, "error", err)
+		return c.failed(err, auth.LoginURL(), returnTo)
+	}
+
+	if twofactor.Enabled(user) {
+		auth.LogOut(c.Controller)
+		twofactor.MarkPending(c.Controller, user.UserId())
+		c.Session[returnToKey] = returnTo
+		return c.Redirect(twofactor.VerifyURL())
+	}
+
+	auth.LogIn(c.Controller, user)
+	return c.Redirect(auth.ReturnTo(returnTo))
+}
+
+// TwoFactor checks the TOTP or recovery code of a user who passed the password check, then logs the user in.
+func (c Auth) TwoFactor(code string) revel.Result {
+	userId := twofactor.PendingUser(c.Controller)
+	if userId == "" {
+		return c.failed(errors.New("no pending login"), auth.LoginURL(), "")
+	}
+
+	user := auth.NewUser(userId, "")
+	err := auth.Store.Load(user)
+	if err != nil {
+		return c.RenderError(err)
+	}
+	twoFactorUser, ok := user.(twofactor.User)
+	if !ok {
+		return c.RenderError(errors.New("user model does not implement twofactor.User"))
+	}
+
+	if ok, err = twofactor.VerifyCode(twoFactorUser, code); err == nil && !ok {
+		err = errors.New("invalid code")
+	}
+	if err != nil {
+		c.Log.Warn("Two-factor check failed", "user", userId, "error", err)
+		return c.failed(err, twofactor.VerifyURL(), "")
+	}
+
+	returnTo, _ := c.Session[returnToKey].(string)
+	auth.LogIn(c.Controller, user)
+	return c.Redirect(auth.ReturnTo(returnTo))
+}
+
+// Logout ends the session and redirects to the `auth.logout.redirect` page.
+func (c Auth) Logout() revel.Result {
+	auth.LogOut(c.Controller)
+	return c.Redirect(auth.LogoutRedirect())
+}
+
+// failed answers a failed login with 401 or 429 to JSON requests, others go back to page with a flash error.
+func (c Auth) failed(err error, page, returnTo string) revel.Result {
+	message, status := "Invalid username or password.", http.StatusUnauthorized
+	if page == twofactor.VerifyURL() {
+		message = "Invalid code."
+	}
+	if _, throttled := err.(*auth.ThrottledError); throttled || err == twofactor.ErrTooManyAttempts {
+		message, status = "Too many failed attempts, please try again later.", http.StatusTooManyRequests
+	}
+
+	if c.Request.Format == "json" {
+		c.Response.Status = status
+		return c.RenderJSON(map[string]string{"error": message})
+	}
+
+	c.Flash.Error(message)
+	if returnTo != "" {
+		page += "?return_to=" + url.QueryEscape(returnTo)
+	}
+	return c.Redirect(page)
+}
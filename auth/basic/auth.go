@@ -1,11 +1,14 @@
 package auth
 
-// "errors"
+import "errors"
 
 var Store StorageDriver
 
 // Store = gormauth.NewGormAuthDriver()
 
+// ErrUserNotFound is returned by a StorageDriver when Load finds no user for the UserId.
+var ErrUserNotFound = errors.New("auth: user not found")
+
 type UserAuth interface {
 	// getters/setters implemented by the app-level model
 	UserId() string
@@ -6,12 +6,14 @@ import (
 
 	auth "github.com/revel/modules/auth/basic"
 	"github.com/revel/modules/auth/basic/driver/secret"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type User struct {
 	email    string
 	password string
 	hashpass string
+	totp     string
 
 	secret.BcryptAuth // SecurityDriver for testing
 }
@@ -41,6 +43,10 @@ func (u *User) SetHashedSecret(hpass string) {
 	u.hashpass = hpass
 }
 
+func (u *User) TOTPSecret() string {
+	return u.totp
+}
+
 // func (u *User) Load() string
 
 type TestStore struct {
@@ -53,9 +59,13 @@ func (ts *TestStore) Save(user interface{}) error {
 		return errors.New("TestStore.Save() expected arg of type User")
 	}
 
-	hPass, err := u.HashSecret(u.Secret())
-	if err != nil {
-		return err
+	// keep a secret hashed beforehand, like a rehashed one
+	hPass := u.HashedSecret()
+	if hPass == "" {
+		var err error
+		if hPass, err = u.HashSecret(u.Secret()); err != nil {
+			return err
+		}
 	}
 	ts.data[u.UserId()] = hPass
 
@@ -70,7 +80,7 @@ func (ts *TestStore) Load(user interface{}) error {
 
 	hpass, ok := ts.data[u.UserId()]
 	if !ok {
-		return errors.New("Record Not Found")
+		return auth.ErrUserNotFound
 	}
 	u.SetHashedSecret(hpass)
 	return nil
@@ -133,3 +143,60 @@ func TestAuthenticate(t *testing.T) {
 		t.Errorf("Should have failed to authenticate user: %v\n", err)
 	}
 }
+
+func TestRehashOnAuthenticate(t *testing.T) {
+	store := &TestStore{
+		data: make(map[string]string),
+	}
+	auth.Store = store
+
+	// hash created before the bcrypt cost was raised
+	outdated, _ := bcrypt.GenerateFromPassword([]byte("demopass"), bcrypt.MinCost)
+	store.data["demo@domain.com"] = string(outdated)
+
+	u := NewUser("demo@domain.com", "demopass")
+	if err := auth.Store.Load(u); err != nil {
+		t.Fatalf("Should have loaded user: %v", err)
+	}
+	ok, err := auth.Authenticate(u)
+	if !ok || err != nil {
+		t.Fatalf("Should have authenticated user: %v", err)
+	}
+
+	if cost, _ := bcrypt.Cost([]byte(store.data["demo@domain.com"])); cost != bcrypt.DefaultCost {
+		t.Errorf("Should have saved the rehashed secret, got cost %d", cost)
+	}
+	if u.NeedsRehash() {
+		t.Error("Should have updated the user hash")
+	}
+
+	// a wrong secret never touches the stored hash
+	stored := store.data["demo@domain.com"]
+	fail := NewUser("demo@domain.com", "invalid")
+	fail.SetHashedSecret(string(outdated))
+	if ok, _ := auth.Authenticate(fail); ok || store.data["demo@domain.com"] != stored {
+		t.Error("Should have failed to authenticate without saving")
+	}
+}
+
+func TestRehashLegacyPassword(t *testing.T) {
+	store := &TestStore{
+		data: make(map[string]string),
+	}
+	auth.Store = store
+
+	// password set before the policy required 8 characters
+	outdated, _ := bcrypt.GenerateFromPassword([]byte("demo"), bcrypt.MinCost)
+	store.data["legacy@domain.com"] = string(outdated)
+
+	u := NewUser("legacy@domain.com", "demo")
+	if err := auth.Store.Load(u); err != nil {
+		t.Fatalf("Should have loaded user: %v", err)
+	}
+	if ok, err := auth.Authenticate(u); !ok || err != nil {
+		t.Fatalf("Should have authenticated user: %v", err)
+	}
+	if cost, _ := bcrypt.Cost([]byte(store.data["legacy@domain.com"])); cost != bcrypt.DefaultCost {
+		t.Errorf("Should have rehashed the legacy secret, got cost %d", cost)
+	}
+}
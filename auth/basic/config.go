@@ -0,0 +1,18 @@
+package auth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// DurationConfig returns the duration set for the key in app.conf, e.g. "15m", or value when it is not set.
+// It panics when the setting is not a valid duration.
+func DurationConfig(key string, value time.Duration) time.Duration {
+	value, err := time.ParseDuration(revel.Config.StringDefault(key, value.String()))
+	if err != nil {
+		panic(fmt.Sprintf("invalid %s: %v", key, err))
+	}
+	return value
+}
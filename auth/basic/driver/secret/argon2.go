@@ -0,0 +1,139 @@
+package secret
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strconv"
+
+	auth "github.com/revel/modules/auth/basic"
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2Params are the argon2id cost parameters for new hashes.
+type Argon2Params struct {
+	Memory  uint32 // in KiB
+	Time    uint32 // number of passes
+	Threads uint8
+	SaltLen int
+	KeyLen  uint32
+}
+
+// DefaultArgon2Params are used by Argon2Auth and MultiAuth, set from app.conf at startup.
+var DefaultArgon2Params = Argon2Params{
+	Memory:  64 * 1024,
+	Time:    3,
+	Threads: 2,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// Argon2Auth is a Revel auth security driver hashing secrets with argon2id.
+// Hashes are stored as PHC strings, e.g. "$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>",
+// so changing the parameters does not invalidate existing hashes.
+//
+// It is used like BcryptAuth, embed it into your User model and set the UserContext.
+type Argon2Auth struct {
+	UserContext auth.UserAuth
+}
+
+// HashSecret returns the argon2id hash of the password and stores it with SetHashedSecret.
+// It expects an argument of type string, which is the plain text password, checked against the auth.Policy.
+// Without argument it returns the stored hash.
+func (aa *Argon2Auth) HashSecret(args ...interface{}) (string, error) {
+	if auth.Store == nil {
+		return "", errors.New("auth module StorageDriver not set")
+	}
+	password, set, err := passwordArg(args)
+	if err != nil || !set {
+		return aa.UserContext.HashedSecret(), err
+	}
+	if err := auth.CheckPassword(password, aa.UserContext.UserId()); err != nil {
+		return "", err
+	}
+	if err := aa.setHash(password); err != nil {
+		return "", err
+	}
+	return aa.UserContext.HashedSecret(), nil
+}
+
+// Rehash hashes the plain text Secret() again with the current settings and stores it with SetHashedSecret.
+// The password is not checked against the auth.Policy, it was accepted when it was set.
+func (aa *Argon2Auth) Rehash() error {
+	return aa.setHash(aa.UserContext.Secret())
+}
+
+func (aa *Argon2Auth) setHash(password string) error {
+	hash, err := hashArgon2(password, DefaultArgon2Params)
+	if err != nil {
+		return err
+	}
+	aa.UserContext.SetHashedSecret(hash)
+	return nil
+}
+
+// Authenticate compares the plain text Secret() of the user with the HashedSecret().
+// It returns true on success and false if error or password mismatch.
+func (aa *Argon2Auth) Authenticate() (bool, error) {
+	return verifyArgon2(aa.UserContext.HashedSecret(), aa.UserContext.Secret())
+}
+
+// NeedsRehash returns true when the stored hash was not created with the DefaultArgon2Params.
+func (aa *Argon2Auth) NeedsRehash() bool {
+	return argon2NeedsRehash(aa.UserContext.HashedSecret())
+}
+
+func hashArgon2(password string, params Argon2Params) (string, error) {
+	salt, err := newSalt(params.SaltLen)
+	if err != nil {
+		return "", err
+	}
+
+	h := &phcHash{
+		id: AlgorithmArgon2id,
+		params: map[string]string{
+			"v": strconv.Itoa(argon2.Version),
+			"m": strconv.FormatUint(uint64(params.Memory), 10),
+			"t": strconv.FormatUint(uint64(params.Time), 10),
+			"p": strconv.Itoa(int(params.Threads)),
+		},
+		salt: salt,
+		hash: argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, params.KeyLen),
+	}
+	return h.String(), nil
+}
+
+func parseArgon2(encoded string) (*phcHash, Argon2Params, error) {
+	h, err := parsePHC(encoded)
+	if err != nil || h.id != AlgorithmArgon2id {
+		return nil, Argon2Params{}, errMalformedHash
+	}
+	if version, err := h.intParam("v"); err != nil || version != argon2.Version {
+		return nil, Argon2Params{}, errors.New("unsupported argon2 version")
+	}
+
+	var params Argon2Params
+	memory, err1 := h.intParam("m")
+	time, err2 := h.intParam("t")
+	threads, err3 := h.intParam("p")
+	if err1 != nil || err2 != nil || err3 != nil || memory <= 0 || time <= 0 || threads <= 0 || threads > 255 {
+		return nil, params, errMalformedHash
+	}
+	params.Memory, params.Time, params.Threads = uint32(memory), uint32(time), uint8(threads)
+	params.SaltLen, params.KeyLen = len(h.salt), uint32(len(h.hash))
+	return h, params, nil
+}
+
+func verifyArgon2(encoded, password string) (bool, error) {
+	h, params, err := parseArgon2(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	hash := argon2.IDKey([]byte(password), h.salt, params.Time, params.Memory, params.Threads, params.KeyLen)
+	return subtle.ConstantTimeCompare(hash, h.hash) == 1, nil
+}
+
+func argon2NeedsRehash(encoded string) bool {
+	_, params, err := parseArgon2(encoded)
+	return err != nil || params != DefaultArgon2Params
+}
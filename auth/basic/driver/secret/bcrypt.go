@@ -26,7 +26,7 @@ type BcryptAuth struct {
 }
 
 // Bcrypt Secret() returns the hashed version of the password.
-// It expects an argument of type string, which is the plain text password.
+// It expects an argument of type string, which is the plain text password, checked against the auth.Policy.
 func (ba *BcryptAuth) HashSecret(args ...interface{}) (string, error) {
 	if auth.Store == nil {
 		return "", errors.New("auth module StorageDriver not set")
@@ -43,12 +43,12 @@ func (ba *BcryptAuth) HashSecret(args ...interface{}) (string, error) {
 		if !ok {
 			return "", errors.New("wrong argument type provided, expected plaintext password as string")
 		}
-		hPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-		if err != nil {
+		if err := auth.CheckPassword(password, ba.UserContext.UserId()); err != nil {
+			return "", err
+		}
+		if err := ba.setHash(password); err != nil {
 			return "", err
 		}
-
-		ba.UserContext.SetHashedSecret(string(hPass))
 		return ba.UserContext.HashedSecret(), nil
 	}
 
@@ -56,6 +56,31 @@ func (ba *BcryptAuth) HashSecret(args ...interface{}) (string, error) {
 	return "", errors.New("too many arguments provided, expected one")
 }
 
+// Rehash hashes the plain text Secret() again with the current cost and stores it with SetHashedSecret.
+// The password is not checked against the auth.Policy, it was accepted when it was set.
+func (ba *BcryptAuth) Rehash() error {
+	return ba.setHash(ba.UserContext.Secret())
+}
+
+func (ba *BcryptAuth) setHash(password string) error {
+	if len(password) > bcryptMaxLength {
+		// bcrypt ignores anything after, whatever the policy
+		return errPasswordTooLong
+	}
+	hPass, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+
+	ba.UserContext.SetHashedSecret(string(hPass))
+	return nil
+}
+
+// bcryptMaxLength is the number of bytes of the password used by bcrypt.
+const bcryptMaxLength = 72
+
+var errPasswordTooLong = auth.PolicyErrors{{Rule: auth.RuleMaxLength, Message: "Password must be at most 72 bytes long."}}
+
 // Bycrypt Authenticate() expects a single string argument of the plaintext password
 // It returns true on success and false if error or password mismatch.
 func (ba *BcryptAuth) Authenticate() (bool, error) {
@@ -71,3 +96,13 @@ func (ba *BcryptAuth) Authenticate() (bool, error) {
 	// successfully authenticated
 	return true, nil
 }
+
+// NeedsRehash returns true when the stored hash was not created with the `auth.bcrypt.cost` setting.
+func (ba *BcryptAuth) NeedsRehash() bool {
+	return bcryptNeedsRehash(ba.UserContext.HashedSecret())
+}
+
+func bcryptNeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != bcryptCost
+}
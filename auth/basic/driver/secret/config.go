@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/revel/revel"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Settings of the secret drivers, read from app.conf (see the auth/basic README).
+var (
+	algorithm  = AlgorithmArgon2id
+	bcryptCost = bcrypt.DefaultCost
+)
+
+func init() {
+	revel.OnAppStart(loadConfig)
+}
+
+func loadConfig() {
+	algorithm = revel.Config.StringDefault("auth.secret.algorithm", AlgorithmArgon2id)
+	switch algorithm {
+	case AlgorithmArgon2id, AlgorithmScrypt, AlgorithmBcrypt:
+	default:
+		panic(fmt.Sprintf("auth.secret.algorithm: unknown algorithm %q", algorithm))
+	}
+
+	bcryptCost = intConfig("auth.bcrypt.cost", bcrypt.DefaultCost, bcrypt.MinCost, bcrypt.MaxCost)
+
+	// checked before the conversions, e.g. 256 threads would wrap to 0
+	DefaultArgon2Params.Memory = uint32(intConfig("auth.argon2.memory", int(DefaultArgon2Params.Memory), 1, math.MaxInt32))
+	DefaultArgon2Params.Time = uint32(intConfig("auth.argon2.time", int(DefaultArgon2Params.Time), 1, math.MaxInt32))
+	DefaultArgon2Params.Threads = uint8(intConfig("auth.argon2.threads", int(DefaultArgon2Params.Threads), 1, math.MaxUint8))
+
+	DefaultScryptParams.LogN = intConfig("auth.scrypt.ln", DefaultScryptParams.LogN, 1, 31)
+	DefaultScryptParams.R = intConfig("auth.scrypt.r", DefaultScryptParams.R, 1, scryptMaxRP)
+	DefaultScryptParams.P = intConfig("auth.scrypt.p", DefaultScryptParams.P, 1, scryptMaxRP)
+	if DefaultScryptParams.R*DefaultScryptParams.P > scryptMaxRP {
+		panic(fmt.Sprintf("auth.scrypt.r, auth.scrypt.p: r * p must be at most %d", scryptMaxRP))
+	}
+}
+
+// scryptMaxRP is the largest product of the scrypt r and p accepted by scrypt.Key.
+const scryptMaxRP = 1<<30 - 1
+
+// intConfig returns the app.conf setting of the key, which must be between min and max.
+func intConfig(key string, value, min, max int) int {
+	value = revel.Config.IntDefault(key, value)
+	if value < min || value > max {
+		panic(fmt.Sprintf("%s: must be between %d and %d", key, min, max))
+	}
+	return value
+}
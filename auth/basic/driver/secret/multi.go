@@ -0,0 +1,128 @@
+package secret
+
+import (
+	"errors"
+	"strings"
+
+	auth "github.com/revel/modules/auth/basic"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// MultiAuth is a Revel auth security driver supporting bcrypt, argon2id and scrypt.
+// New secrets are hashed with the algorithm set by `auth.secret.algorithm` (argon2id by default),
+// stored hashes are verified with the algorithm they were created with.
+// This allows apps to move to another algorithm without invalidating existing passwords.
+//
+// It is used like BcryptAuth, embed it into your User model and set the UserContext.
+type MultiAuth struct {
+	UserContext auth.UserAuth
+}
+
+// HashSecret returns the hash of the password, using the configured algorithm, and stores it with SetHashedSecret.
+// It expects an argument of type string, which is the plain text password, checked against the auth.Policy.
+// Without argument it returns the stored hash.
+func (ma *MultiAuth) HashSecret(args ...interface{}) (string, error) {
+	if auth.Store == nil {
+		return "", errors.New("auth module StorageDriver not set")
+	}
+	password, set, err := passwordArg(args)
+	if err != nil || !set {
+		return ma.UserContext.HashedSecret(), err
+	}
+	if err := auth.CheckPassword(password, ma.UserContext.UserId()); err != nil {
+		return "", err
+	}
+	if err := ma.setHash(password); err != nil {
+		return "", err
+	}
+	return ma.UserContext.HashedSecret(), nil
+}
+
+// Rehash hashes the plain text Secret() again with the current settings and stores it with SetHashedSecret.
+// The password is not checked against the auth.Policy, it was accepted when it was set.
+func (ma *MultiAuth) Rehash() error {
+	return ma.setHash(ma.UserContext.Secret())
+}
+
+func (ma *MultiAuth) setHash(password string) error {
+	hash, err := Hash(password)
+	if err != nil {
+		return err
+	}
+	ma.UserContext.SetHashedSecret(hash)
+	return nil
+}
+
+// Authenticate compares the plain text Secret() of the user with the HashedSecret(),
+// using the algorithm of the stored hash.
+// It returns true on success and false if error or password mismatch.
+func (ma *MultiAuth) Authenticate() (bool, error) {
+	return Verify(ma.UserContext.HashedSecret(), ma.UserContext.Secret())
+}
+
+// NeedsRehash returns true when the stored hash was not created with the configured algorithm and cost.
+func (ma *MultiAuth) NeedsRehash() bool {
+	return NeedsRehash(ma.UserContext.HashedSecret())
+}
+
+// Hash returns the hash of the password using the configured algorithm.
+func Hash(password string) (string, error) {
+	switch algorithm {
+	case AlgorithmArgon2id:
+		return hashArgon2(password, DefaultArgon2Params)
+	case AlgorithmScrypt:
+		return hashScrypt(password, DefaultScryptParams)
+	case AlgorithmBcrypt:
+		if len(password) > bcryptMaxLength {
+			return "", errPasswordTooLong
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+		return string(hash), err
+	}
+	return "", errors.New("unknown hash algorithm " + algorithm)
+}
+
+// Verify compares the password with a hash created by any of the supported algorithms.
+func Verify(hash, password string) (bool, error) {
+	switch HashAlgorithm(hash) {
+	case AlgorithmArgon2id:
+		return verifyArgon2(hash, password)
+	case AlgorithmScrypt:
+		return verifyScrypt(hash, password)
+	case AlgorithmBcrypt:
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if err == bcrypt.ErrMismatchedHashAndPassword {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, errMalformedHash
+}
+
+// NeedsRehash returns true when the hash was not created with the configured algorithm and cost.
+func NeedsRehash(hash string) bool {
+	if HashAlgorithm(hash) != algorithm {
+		return true
+	}
+
+	switch algorithm {
+	case AlgorithmArgon2id:
+		return argon2NeedsRehash(hash)
+	case AlgorithmScrypt:
+		return scryptNeedsRehash(hash)
+	}
+	return bcryptNeedsRehash(hash)
+}
+
+// HashAlgorithm returns the algorithm a hash was created with, or an empty string if unknown.
+func HashAlgorithm(hash string) string {
+	switch {
+	case strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$"):
+		return AlgorithmArgon2id
+	case strings.HasPrefix(hash, "$"+AlgorithmScrypt+"$"):
+		return AlgorithmScrypt
+	case strings.HasPrefix(hash, "$2a$"), strings.HasPrefix(hash, "$2b$"), strings.HasPrefix(hash, "$2y$"):
+		return AlgorithmBcrypt
+	}
+	return ""
+}
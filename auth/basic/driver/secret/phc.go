@@ -0,0 +1,125 @@
+package secret
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Hash algorithms understood by MultiAuth.
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmScrypt   = "scrypt"
+)
+
+var errMalformedHash = errors.New("malformed hash string")
+
+// Shortest salt and hash accepted in a PHC string.
+// An empty hash would match the empty output of a zero length key derivation, whatever the password.
+const (
+	minSaltLen = 8
+	minHashLen = 16
+)
+
+// phcHash is a hash string in the PHC string format,
+// e.g. "$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>".
+type phcHash struct {
+	id     string
+	params map[string]string
+	salt   []byte
+	hash   []byte
+}
+
+func (h *phcHash) String() string {
+	return "$" + h.id + "$" + encodeParams(h.params) +
+		"$" + base64.RawStdEncoding.EncodeToString(h.salt) +
+		"$" + base64.RawStdEncoding.EncodeToString(h.hash)
+}
+
+// parsePHC parses a PHC string, the version segment of argon2 is merged into the params.
+func parsePHC(encoded string) (*phcHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 5 || parts[0] != "" {
+		return nil, errMalformedHash
+	}
+
+	h := &phcHash{id: parts[1], params: map[string]string{}}
+	for _, segment := range parts[2 : len(parts)-2] {
+		for _, param := range strings.Split(segment, ",") {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				return nil, errMalformedHash
+			}
+			h.params[kv[0]] = kv[1]
+		}
+	}
+
+	var err error
+	if h.salt, err = base64.RawStdEncoding.DecodeString(parts[len(parts)-2]); err != nil {
+		return nil, errMalformedHash
+	}
+	if h.hash, err = base64.RawStdEncoding.DecodeString(parts[len(parts)-1]); err != nil {
+		return nil, errMalformedHash
+	}
+	if len(h.salt) < minSaltLen || len(h.hash) < minHashLen {
+		return nil, errMalformedHash
+	}
+	return h, nil
+}
+
+// intParam returns a numeric parameter of the hash.
+func (h *phcHash) intParam(name string) (int, error) {
+	value, err := strconv.Atoi(h.params[name])
+	if err != nil {
+		return 0, errMalformedHash
+	}
+	return value, nil
+}
+
+// encodeParams writes params in a fixed order, "v" goes into its own segment as argon2 expects.
+func encodeParams(params map[string]string) string {
+	var version string
+	var list []string
+	for _, name := range []string{"v", "m", "t", "ln", "r", "p"} {
+		value, ok := params[name]
+		if !ok {
+			continue
+		}
+		if name == "v" {
+			version = "v=" + value + "$"
+			continue
+		}
+		list = append(list, name+"="+value)
+	}
+	return version + strings.Join(list, ",")
+}
+
+func newSalt(length int) ([]byte, error) {
+	salt := make([]byte, length)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// passwordArg implements the argument convention of the secret drivers' HashSecret:
+// no argument reads the stored hash, one string argument is a new plain text password.
+func passwordArg(args []interface{}) (password string, set bool, err error) {
+	switch len(args) {
+	case 0:
+		return "", false, nil
+	case 1:
+		password, ok := args[0].(string)
+		if !ok {
+			return "", false, errors.New("wrong argument type provided, expected plaintext password as string")
+		}
+		return password, true, nil
+	}
+
+	// bad argument count
+	return "", false, errors.New("too many arguments provided, expected one")
+}
@@ -0,0 +1,143 @@
+package secret
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strconv"
+
+	auth "github.com/revel/modules/auth/basic"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ScryptParams are the scrypt cost parameters for new hashes.
+type ScryptParams struct {
+	LogN    int // log2 of the CPU/memory cost N
+	R       int // block size
+	P       int // parallelization
+	SaltLen int
+	KeyLen  int
+}
+
+// DefaultScryptParams are used by ScryptAuth and MultiAuth, set from app.conf at startup.
+var DefaultScryptParams = ScryptParams{
+	LogN:    15,
+	R:       8,
+	P:       1,
+	SaltLen: 16,
+	KeyLen:  32,
+}
+
+// ScryptAuth is a Revel auth security driver hashing secrets with scrypt.
+// Hashes are stored as PHC strings, e.g. "$scrypt$ln=15,r=8,p=1$<salt>$<hash>",
+// so changing the parameters does not invalidate existing hashes.
+//
+// It is used like BcryptAuth, embed it into your User model and set the UserContext.
+type ScryptAuth struct {
+	UserContext auth.UserAuth
+}
+
+// HashSecret returns the scrypt hash of the password and stores it with SetHashedSecret.
+// It expects an argument of type string, which is the plain text password, checked against the auth.Policy.
+// Without argument it returns the stored hash.
+func (sa *ScryptAuth) HashSecret(args ...interface{}) (string, error) {
+	if auth.Store == nil {
+		return "", errors.New("auth module StorageDriver not set")
+	}
+	password, set, err := passwordArg(args)
+	if err != nil || !set {
+		return sa.UserContext.HashedSecret(), err
+	}
+	if err := auth.CheckPassword(password, sa.UserContext.UserId()); err != nil {
+		return "", err
+	}
+	if err := sa.setHash(password); err != nil {
+		return "", err
+	}
+	return sa.UserContext.HashedSecret(), nil
+}
+
+// Rehash hashes the plain text Secret() again with the current settings and stores it with SetHashedSecret.
+// The password is not checked against the auth.Policy, it was accepted when it was set.
+func (sa *ScryptAuth) Rehash() error {
+	return sa.setHash(sa.UserContext.Secret())
+}
+
+func (sa *ScryptAuth) setHash(password string) error {
+	hash, err := hashScrypt(password, DefaultScryptParams)
+	if err != nil {
+		return err
+	}
+	sa.UserContext.SetHashedSecret(hash)
+	return nil
+}
+
+// Authenticate compares the plain text Secret() of the user with the HashedSecret().
+// It returns true on success and false if error or password mismatch.
+func (sa *ScryptAuth) Authenticate() (bool, error) {
+	return verifyScrypt(sa.UserContext.HashedSecret(), sa.UserContext.Secret())
+}
+
+// NeedsRehash returns true when the stored hash was not created with the DefaultScryptParams.
+func (sa *ScryptAuth) NeedsRehash() bool {
+	return scryptNeedsRehash(sa.UserContext.HashedSecret())
+}
+
+func hashScrypt(password string, params ScryptParams) (string, error) {
+	salt, err := newSalt(params.SaltLen)
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := scrypt.Key([]byte(password), salt, 1<<uint(params.LogN), params.R, params.P, params.KeyLen)
+	if err != nil {
+		return "", err
+	}
+
+	h := &phcHash{
+		id: AlgorithmScrypt,
+		params: map[string]string{
+			"ln": strconv.Itoa(params.LogN),
+			"r":  strconv.Itoa(params.R),
+			"p":  strconv.Itoa(params.P),
+		},
+		salt: salt,
+		hash: hash,
+	}
+	return h.String(), nil
+}
+
+func parseScrypt(encoded string) (*phcHash, ScryptParams, error) {
+	h, err := parsePHC(encoded)
+	if err != nil || h.id != AlgorithmScrypt {
+		return nil, ScryptParams{}, errMalformedHash
+	}
+
+	var params ScryptParams
+	logN, err1 := h.intParam("ln")
+	r, err2 := h.intParam("r")
+	p, err3 := h.intParam("p")
+	if err1 != nil || err2 != nil || err3 != nil || logN <= 0 || logN > 31 {
+		return nil, params, errMalformedHash
+	}
+	params.LogN, params.R, params.P = logN, r, p
+	params.SaltLen, params.KeyLen = len(h.salt), len(h.hash)
+	return h, params, nil
+}
+
+func verifyScrypt(encoded, password string) (bool, error) {
+	h, params, err := parseScrypt(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	hash, err := scrypt.Key([]byte(password), h.salt, 1<<uint(params.LogN), params.R, params.P, params.KeyLen)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(hash, h.hash) == 1, nil
+}
+
+func scryptNeedsRehash(encoded string) bool {
+	_, params, err := parseScrypt(encoded)
+	return err != nil || params != DefaultScryptParams
+}
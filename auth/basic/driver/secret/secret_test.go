@@ -0,0 +1,214 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/revel/config"
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type testUser struct {
+	secret   string
+	hashpass string
+}
+
+func (u *testUser) UserId() string                                 { return "demo@domain.com" }
+func (u *testUser) Secret() string                                 { return u.secret }
+func (u *testUser) HashedSecret() string                           { return u.hashpass }
+func (u *testUser) SetHashedSecret(hpass string)                   { u.hashpass = hpass }
+func (u *testUser) Authenticate() (bool, error)                    { return false, nil }
+func (u *testUser) HashSecret(args ...interface{}) (string, error) { return "", nil }
+
+type testStore struct{}
+
+func (testStore) Save(interface{}) error { return nil }
+func (testStore) Load(interface{}) error { return nil }
+
+func init() {
+	// keep the tests fast
+	DefaultArgon2Params.Memory, DefaultArgon2Params.Time = 1024, 1
+	DefaultScryptParams.LogN = 10
+	bcryptCost = bcrypt.MinCost
+}
+
+func TestArgon2Auth(t *testing.T) {
+	auth.Store = testStore{}
+	u := &testUser{secret: "demopass"}
+	driver := &Argon2Auth{UserContext: u}
+
+	hash, err := driver.HashSecret(u.secret)
+	if err != nil || !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=2$") {
+		t.Fatalf("Should have hashed password, got %q %v", hash, err)
+	}
+	if ok, err := driver.Authenticate(); !ok || err != nil {
+		t.Errorf("Should have authenticated user: %v", err)
+	}
+
+	u.secret = "invalid"
+	if ok, err := driver.Authenticate(); ok || err != nil {
+		t.Errorf("Should have failed to authenticate user: %v", err)
+	}
+}
+
+func TestScryptAuth(t *testing.T) {
+	auth.Store = testStore{}
+	u := &testUser{secret: "demopass"}
+	driver := &ScryptAuth{UserContext: u}
+
+	hash, err := driver.HashSecret(u.secret)
+	if err != nil || !strings.HasPrefix(hash, "$scrypt$ln=10,r=8,p=1$") {
+		t.Fatalf("Should have hashed password, got %q %v", hash, err)
+	}
+	if ok, err := driver.Authenticate(); !ok || err != nil {
+		t.Errorf("Should have authenticated user: %v", err)
+	}
+
+	u.secret = "invalid"
+	if ok, err := driver.Authenticate(); ok || err != nil {
+		t.Errorf("Should have failed to authenticate user: %v", err)
+	}
+}
+
+func TestMultiAuth(t *testing.T) {
+	auth.Store = testStore{}
+	defer func() { algorithm = AlgorithmArgon2id }()
+
+	// hashes created with any algorithm keep working after the configured algorithm changed
+	var hashes []string
+	for _, algorithm = range []string{AlgorithmBcrypt, AlgorithmScrypt, AlgorithmArgon2id} {
+		u := &testUser{secret: "demopass"}
+		hash, err := (&MultiAuth{UserContext: u}).HashSecret(u.secret)
+		if err != nil || HashAlgorithm(hash) != algorithm {
+			t.Fatalf("Should have hashed password with %s, got %q %v", algorithm, hash, err)
+		}
+		hashes = append(hashes, hash)
+	}
+
+	for _, hash := range hashes {
+		u := &testUser{secret: "demopass", hashpass: hash}
+		if ok, err := (&MultiAuth{UserContext: u}).Authenticate(); !ok || err != nil {
+			t.Errorf("Should have authenticated %s hash: %v", HashAlgorithm(hash), err)
+		}
+		u.secret = "invalid"
+		if ok, err := (&MultiAuth{UserContext: u}).Authenticate(); ok || err != nil {
+			t.Errorf("Should have failed to authenticate %s hash: %v", HashAlgorithm(hash), err)
+		}
+	}
+}
+
+func TestMalformedHash(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2id$v=19$m=1024,t=1$c2FsdA$aGFzaA",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$aGFzaA",
+		"$scrypt$ln=x,r=8,p=1$c2FsdA$aGFzaA",
+		"$scrypt$ln=10,r=8,p=1$!!$aGFzaA",
+	} {
+		if ok, err := Verify(hash, "demopass"); ok || err == nil {
+			t.Errorf("Should have rejected malformed hash %q", hash)
+		}
+	}
+}
+
+func TestTruncatedHash(t *testing.T) {
+	argon2Hash, _ := hashArgon2("demopass", DefaultArgon2Params)
+	scryptHash, _ := hashScrypt("demopass", DefaultScryptParams)
+
+	for _, valid := range []string{argon2Hash, scryptHash} {
+		i := strings.LastIndex(valid, "$")
+		j := strings.LastIndex(valid[:i], "$")
+		for _, hash := range []string{
+			// empty hash segment
+			valid[:i+1],
+			// short hash segment
+			valid[:i+1] + "aGFzaA",
+			// short salt segment
+			valid[:j+1] + "c2FsdA" + valid[i:],
+		} {
+			if ok, err := Verify(hash, "wrong-password"); ok || err == nil {
+				t.Errorf("Should have rejected truncated hash %q", hash)
+			}
+		}
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	defer func() { algorithm = AlgorithmArgon2id }()
+
+	current, _ := hashArgon2("demopass", DefaultArgon2Params)
+	weaker := DefaultArgon2Params
+	weaker.Memory /= 2
+	outdated, _ := hashArgon2("demopass", weaker)
+	scryptHash, _ := hashScrypt("demopass", DefaultScryptParams)
+
+	algorithm = AlgorithmArgon2id
+	if NeedsRehash(current) {
+		t.Error("Should not rehash a hash created with the current settings")
+	}
+	if !NeedsRehash(outdated) {
+		t.Error("Should rehash a hash created with a lower cost")
+	}
+	if !NeedsRehash(scryptHash) {
+		t.Error("Should rehash a hash created with another algorithm")
+	}
+
+	bcryptHash, _ := bcrypt.GenerateFromPassword([]byte("demopass"), bcryptCost+1)
+	u := &testUser{secret: "demopass", hashpass: string(bcryptHash)}
+	if !(&BcryptAuth{UserContext: u}).NeedsRehash() {
+		t.Error("Should rehash a bcrypt hash created with another cost")
+	}
+	if !(&MultiAuth{UserContext: u}).NeedsRehash() {
+		t.Error("Should rehash a bcrypt hash when argon2id is configured")
+	}
+	if (&ScryptAuth{UserContext: &testUser{hashpass: scryptHash}}).NeedsRehash() {
+		t.Error("Should not rehash a scrypt hash created with the current settings")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	defer func(conf *config.Context) { revel.Config = conf }(revel.Config)
+	argon2, scrypt, cost := DefaultArgon2Params, DefaultScryptParams, bcryptCost
+	reset := func() { DefaultArgon2Params, DefaultScryptParams, bcryptCost = argon2, scrypt, cost }
+	defer reset()
+
+	for _, setting := range []struct{ key, value string }{
+		{"auth.bcrypt.cost", "3"},
+		{"auth.argon2.memory", "0"},
+		{"auth.argon2.time", "0"},
+		{"auth.argon2.threads", "0"},
+		{"auth.argon2.threads", "256"},
+		{"auth.scrypt.ln", "0"},
+		{"auth.scrypt.ln", "32"},
+		{"auth.scrypt.r", "0"},
+		{"auth.scrypt.p", "-1"},
+		{"auth.scrypt.r", "65536"},
+	} {
+		reset()
+		revel.Config = config.NewContext()
+		revel.Config.SetOption(setting.key, setting.value)
+		if setting.key == "auth.scrypt.r" && setting.value == "65536" {
+			// r * p too large
+			revel.Config.SetOption("auth.scrypt.p", "16384")
+		}
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Should have refused %s = %s", setting.key, setting.value)
+				}
+			}()
+			loadConfig()
+		}()
+	}
+
+	reset()
+	revel.Config = config.NewContext()
+	revel.Config.SetOption("auth.argon2.threads", "255")
+	loadConfig()
+	if DefaultArgon2Params.Threads != 255 {
+		t.Errorf("Should have set 255 argon2 threads, got %d", DefaultArgon2Params.Threads)
+	}
+}
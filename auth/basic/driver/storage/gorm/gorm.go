@@ -0,0 +1,192 @@
+// Package gormauth is an auth/basic StorageDriver keeping users in a table of the gorm module database.
+package gormauth
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/apikey"
+	gormdb "github.com/revel/modules/orm/gorm/app"
+	"github.com/revel/revel"
+)
+
+// GormAuthDriver stores the UserId and HashedSecret of auth.UserAuth users.
+//
+//	revel.OnAppStart(func() {
+//		gormdb.InitDB()
+//		auth.Store = gormauth.NewGormAuthDriver()
+//	})
+type GormAuthDriver struct {
+	// DB is the database holding the table, gormdb.DB when nil.
+	DB           *gorm.DB
+	Table        string
+	UserIdColumn string
+	SecretColumn string
+	// TokenTable holds the tokens used with auth.VerifyToken.
+	TokenTable string
+	// KeyTable holds the API keys of the apikey package.
+	KeyTable string
+	// StepTable holds the last TOTP time step accepted per user, for the twofactor package.
+	StepTable string
+	// RecoveryTable holds the hashed recovery codes of the twofactor package.
+	RecoveryTable string
+	// AutoCreate creates the tables on first use when they do not exist.
+	AutoCreate bool
+
+	created         autoTable
+	tokensCreated   autoTable
+	keysCreated     autoTable
+	stepsCreated    autoTable
+	recoveryCreated autoTable
+}
+
+// NewGormAuthDriver returns a driver on gormdb.DB configured from app.conf.
+func NewGormAuthDriver() *GormAuthDriver {
+	d := &GormAuthDriver{
+		Table:         "auth_users",
+		UserIdColumn:  "user_id",
+		SecretColumn:  "hashed_secret",
+		TokenTable:    "auth_tokens",
+		KeyTable:      "auth_api_keys",
+		StepTable:     "auth_totp_steps",
+		RecoveryTable: "auth_recovery_codes",
+		AutoCreate:    true,
+	}
+	if revel.Config != nil {
+		d.Table = revel.Config.StringDefault("auth.store.table", d.Table)
+		d.UserIdColumn = revel.Config.StringDefault("auth.store.userid", d.UserIdColumn)
+		d.SecretColumn = revel.Config.StringDefault("auth.store.secret", d.SecretColumn)
+		d.TokenTable = revel.Config.StringDefault("auth.token.table", d.TokenTable)
+		d.KeyTable = revel.Config.StringDefault("auth.apikey.table", d.KeyTable)
+		d.StepTable = revel.Config.StringDefault("auth.2fa.step.table", d.StepTable)
+		d.RecoveryTable = revel.Config.StringDefault("auth.2fa.recovery.table", d.RecoveryTable)
+		d.AutoCreate = revel.Config.BoolDefault("auth.store.autocreate", d.AutoCreate)
+	}
+	return d
+}
+
+// Save inserts or updates the user, or the *apikey.Key. Users without HashedSecret() get their Secret() hashed first.
+func (d *GormAuthDriver) Save(user interface{}) error {
+	if key, ok := user.(*apikey.Key); ok {
+		return d.saveKey(key)
+	}
+	u, ok := user.(auth.UserAuth)
+	if !ok {
+		return errors.New("GormAuthDriver.Save() expected arg of type auth.UserAuth or *apikey.Key")
+	}
+	hash := u.HashedSecret()
+	if hash == "" {
+		var err error
+		if hash, err = u.HashSecret(u.Secret()); err != nil {
+			return err
+		}
+	}
+
+	db, err := d.db()
+	if err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("UPDATE "+quote(db, d.Table)+" SET "+quote(db, d.SecretColumn)+" = ? WHERE "+quote(db, d.UserIdColumn)+" = ?", hash, u.UserId())
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+		return tx.Exec("INSERT INTO "+quote(db, d.Table)+" ("+quote(db, d.UserIdColumn)+", "+quote(db, d.SecretColumn)+") VALUES (?, ?)", u.UserId(), hash).Error
+	})
+}
+
+// Load fills in the HashedSecret() of the user with the given UserId(), or the *apikey.Key with the given Prefix.
+// It returns auth.ErrUserNotFound or apikey.ErrKeyNotFound when there is no such user or key.
+func (d *GormAuthDriver) Load(user interface{}) error {
+	if key, ok := user.(*apikey.Key); ok {
+		return d.loadKey(key)
+	}
+	u, ok := user.(auth.UserAuth)
+	if !ok {
+		return errors.New("GormAuthDriver.Load() expected arg of type auth.UserAuth or *apikey.Key")
+	}
+
+	db, err := d.db()
+	if err != nil {
+		return err
+	}
+	var hashes []string
+	err = db.Table(d.Table).Where(quote(db, d.UserIdColumn)+" = ?", u.UserId()).Pluck(quote(db, d.SecretColumn), &hashes).Error
+	if err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return auth.ErrUserNotFound
+	}
+	u.SetHashedSecret(hashes[0])
+	return nil
+}
+
+// CreateTable creates the user table if it does not exist.
+func (d *GormAuthDriver) CreateTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	if db.Dialect().HasTable(d.Table) {
+		return nil
+	}
+	return db.Exec("CREATE TABLE " + quote(db, d.Table) + " (" +
+		quote(db, d.UserIdColumn) + " VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		quote(db, d.SecretColumn) + " VARCHAR(255) NOT NULL)").Error
+}
+
+// db returns the database, creating the table on first use when AutoCreate is set.
+func (d *GormAuthDriver) db() (*gorm.DB, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.created.ensure(d.CreateTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func (d *GormAuthDriver) conn() (*gorm.DB, error) {
+	return database(d.DB)
+}
+
+// database returns db, or gormdb.DB when nil.
+func database(db *gorm.DB) (*gorm.DB, error) {
+	if db != nil {
+		return db, nil
+	}
+	if gormdb.DB == nil {
+		return nil, errors.New("gorm module database not initialized")
+	}
+	return gormdb.DB, nil
+}
+
+func quote(db *gorm.DB, name string) string {
+	return db.Dialect().Quote(name)
+}
+
+// autoTable creates a table once, on first use.
+type autoTable struct {
+	lock  sync.Mutex
+	ready bool
+}
+
+func (t *autoTable) ensure(create func() error) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.ready {
+		return nil
+	}
+	if err := create(); err != nil {
+		return err
+	}
+	t.ready = true
+	return nil
+}
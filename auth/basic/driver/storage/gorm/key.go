@@ -0,0 +1,116 @@
+package gormauth
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/revel/modules/auth/basic/apikey"
+)
+
+const keyColumns = "prefix, hash, user_id, name, scopes, created_at, last_used, revoked_at"
+
+func (d *GormAuthDriver) saveKey(key *apikey.Key) error {
+	db, err := d.keyDb()
+	if err != nil {
+		return err
+	}
+
+	table := quote(db, d.KeyTable)
+	values := []interface{}{key.Hash, key.UserId, key.Name, strings.Join(key.Scopes, " "),
+		unix(key.CreatedAt), unix(key.LastUsed), unix(key.RevokedAt), key.Prefix}
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("UPDATE "+table+" SET hash = ?, user_id = ?, name = ?, scopes = ?, "+
+			"created_at = ?, last_used = ?, revoked_at = ? WHERE prefix = ?", values...)
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+		return tx.Exec("INSERT INTO "+table+" (hash, user_id, name, scopes, created_at, last_used, revoked_at, prefix) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?)", values...).Error
+	})
+}
+
+func (d *GormAuthDriver) loadKey(key *apikey.Key) error {
+	db, err := d.keyDb()
+	if err != nil {
+		return err
+	}
+
+	rows, err := db.Raw("SELECT "+keyColumns+" FROM "+quote(db, d.KeyTable)+" WHERE prefix = ?", key.Prefix).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return apikey.ErrKeyNotFound
+	}
+	var scopes string
+	var created, lastUsed, revoked int64
+	if err := rows.Scan(&key.Prefix, &key.Hash, &key.UserId, &key.Name, &scopes, &created, &lastUsed, &revoked); err != nil {
+		return err
+	}
+	key.Scopes = strings.Fields(scopes)
+	key.CreatedAt, key.LastUsed, key.RevokedAt = fromUnix(created), fromUnix(lastUsed), fromUnix(revoked)
+	return nil
+}
+
+// UseKey sets the LastUsed time of the key with the prefix, unless the key is revoked.
+func (d *GormAuthDriver) UseKey(prefix string, at time.Time) error {
+	db, err := d.keyDb()
+	if err != nil {
+		return err
+	}
+	return db.Exec("UPDATE "+quote(db, d.KeyTable)+" SET last_used = ? WHERE prefix = ? AND revoked_at = 0", unix(at), prefix).Error
+}
+
+// CreateKeyTable creates the API keys table if it does not exist.
+func (d *GormAuthDriver) CreateKeyTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	if db.Dialect().HasTable(d.KeyTable) {
+		return nil
+	}
+	return db.Exec("CREATE TABLE " + quote(db, d.KeyTable) + " (" +
+		"prefix VARCHAR(64) NOT NULL PRIMARY KEY, " +
+		"hash VARCHAR(255) NOT NULL, " +
+		"user_id VARCHAR(255) NOT NULL, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"scopes VARCHAR(1024) NOT NULL, " +
+		"created_at BIGINT NOT NULL, " +
+		"last_used BIGINT NOT NULL, " +
+		"revoked_at BIGINT NOT NULL)").Error
+}
+
+func (d *GormAuthDriver) keyDb() (*gorm.DB, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.keysCreated.ensure(d.CreateKeyTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// unix returns the unix time of t, 0 for the zero time.
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
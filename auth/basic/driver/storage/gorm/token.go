@@ -0,0 +1,49 @@
+package gormauth
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// UseToken marks a token used and returns false if it was used before.
+func (d *GormAuthDriver) UseToken(id string, expires time.Time) (fresh bool, err error) {
+	db, err := d.conn()
+	if err != nil {
+		return
+	}
+	if d.AutoCreate {
+		if err = d.tokensCreated.ensure(d.CreateTokenTable); err != nil {
+			return
+		}
+	}
+
+	table := quote(db, d.TokenTable)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM "+table+" WHERE expires < ?", time.Now().Unix()).Error; err != nil {
+			return err
+		}
+
+		var used int
+		if err := tx.Table(d.TokenTable).Where("token_id = ?", id).Count(&used).Error; err != nil || used > 0 {
+			return err
+		}
+		fresh = true
+		return tx.Exec("INSERT INTO "+table+" (token_id, expires) VALUES (?, ?)", id, expires.Unix()).Error
+	})
+	return fresh && err == nil, err
+}
+
+// CreateTokenTable creates the used tokens table if it does not exist.
+func (d *GormAuthDriver) CreateTokenTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	if db.Dialect().HasTable(d.TokenTable) {
+		return nil
+	}
+	return db.Exec("CREATE TABLE " + quote(db, d.TokenTable) + " (" +
+		"token_id VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		"expires BIGINT NOT NULL)").Error
+}
@@ -0,0 +1,152 @@
+package gormauth
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/revel/revel"
+)
+
+// GormAttemptTracker is an auth.AttemptTracker keeping the failed logins in a table,
+// shared by all the app servers.
+//
+//	revel.OnAppStart(func() {
+//		gormdb.InitDB()
+//		auth.Tracker = gormauth.NewGormAttemptTracker()
+//	})
+type GormAttemptTracker struct {
+	// DB is the database holding the table, gormdb.DB when nil.
+	DB    *gorm.DB
+	Table string
+	// AutoCreate creates the table on first use when it does not exist.
+	AutoCreate bool
+
+	created autoTable
+}
+
+// NewGormAttemptTracker returns a tracker on gormdb.DB configured from app.conf.
+func NewGormAttemptTracker() *GormAttemptTracker {
+	t := &GormAttemptTracker{
+		Table:      "auth_attempts",
+		AutoCreate: true,
+	}
+	if revel.Config != nil {
+		t.Table = revel.Config.StringDefault("auth.throttle.table", t.Table)
+		t.AutoCreate = revel.Config.BoolDefault("auth.store.autocreate", t.AutoCreate)
+	}
+	return t
+}
+
+// Failures returns the number of failures recorded for the key and the time of the last one.
+func (t *GormAttemptTracker) Failures(key string) (count int, last time.Time, err error) {
+	db, err := t.db()
+	if err != nil {
+		return
+	}
+	return t.load(db, key)
+}
+
+// AddFailure records a failure for the key and returns the updated count.
+// The count is incremented in place, so the failures of concurrent logins are all counted.
+func (t *GormAttemptTracker) AddFailure(key string, at time.Time, window time.Duration) (count int, err error) {
+	db, err := t.db()
+	if err != nil {
+		return
+	}
+
+	count, found, err := t.increment(db, key, at, window)
+	if err != nil || found {
+		return
+	}
+	err = db.Exec("INSERT INTO "+quote(db, t.Table)+" (attempt_key, failures, last_failure) VALUES (?, ?, ?)", key, 1, at.UnixNano()).Error
+	if err == nil {
+		return 1, nil
+	}
+
+	// another server inserted the key first, count the failure on its row
+	if count, found, _ = t.increment(db, key, at, window); found {
+		return count, nil
+	}
+	return 0, err
+}
+
+// increment adds a failure to the row of the key when there is one, and reads the count
+// in the same transaction, while the row is locked by the update.
+func (t *GormAttemptTracker) increment(db *gorm.DB, key string, at time.Time, window time.Duration) (count int, found bool, err error) {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Exec("UPDATE "+quote(tx, t.Table)+
+			" SET failures = CASE WHEN last_failure < ? THEN 1 ELSE failures + 1 END, last_failure = ? WHERE attempt_key = ?",
+			at.Add(-window).UnixNano(), at.UnixNano(), key)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		found = true
+		count, _, err = t.load(tx, key)
+		return err
+	})
+	return count, found && err == nil, err
+}
+
+// RemoveFailure takes back one failure of the key.
+func (t *GormAttemptTracker) RemoveFailure(key string) error {
+	db, err := t.db()
+	if err != nil {
+		return err
+	}
+	return db.Exec("UPDATE "+quote(db, t.Table)+" SET failures = failures - 1 WHERE attempt_key = ? AND failures > 0", key).Error
+}
+
+// Reset forgets the failures of the key.
+func (t *GormAttemptTracker) Reset(key string) error {
+	db, err := t.db()
+	if err != nil {
+		return err
+	}
+	return db.Exec("DELETE FROM "+quote(db, t.Table)+" WHERE attempt_key = ?", key).Error
+}
+
+// CreateTable creates the attempts table if it does not exist.
+func (t *GormAttemptTracker) CreateTable() error {
+	db, err := database(t.DB)
+	if err != nil {
+		return err
+	}
+	if db.Dialect().HasTable(t.Table) {
+		return nil
+	}
+	return db.Exec("CREATE TABLE " + quote(db, t.Table) + " (" +
+		"attempt_key VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		"failures INTEGER NOT NULL, " +
+		"last_failure BIGINT NOT NULL)").Error
+}
+
+func (t *GormAttemptTracker) load(db *gorm.DB, key string) (count int, last time.Time, err error) {
+	rows, err := db.Raw("SELECT failures, last_failure FROM "+quote(db, t.Table)+" WHERE attempt_key = ?", key).Rows()
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		var nanos int64
+		if err = rows.Scan(&count, &nanos); err != nil {
+			return
+		}
+		last = time.Unix(0, nanos)
+	}
+	return count, last, rows.Err()
+}
+
+// db returns the database, creating the table on first use when AutoCreate is set.
+func (t *GormAttemptTracker) db() (*gorm.DB, error) {
+	db, err := database(t.DB)
+	if err != nil {
+		return nil, err
+	}
+	if t.AutoCreate {
+		if err := t.created.ensure(t.CreateTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
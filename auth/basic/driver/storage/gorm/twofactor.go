@@ -0,0 +1,141 @@
+package gormauth
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// UseStep records step as the last TOTP time step accepted for the user,
+// and returns false when it is not after the last step recorded.
+func (d *GormAuthDriver) UseStep(userId string, step int64) (bool, error) {
+	db, err := d.stepDb()
+	if err != nil {
+		return false, err
+	}
+
+	used, found, err := d.updateStep(db, userId, step)
+	if err != nil || found {
+		return used, err
+	}
+	err = db.Exec("INSERT INTO "+quote(db, d.StepTable)+" (user_id, last_step) VALUES (?, ?)", userId, step).Error
+	if err == nil {
+		return true, nil
+	}
+
+	// another server recorded a step of the user first
+	if used, found, _ = d.updateStep(db, userId, step); found {
+		return used, nil
+	}
+	return false, err
+}
+
+// updateStep records the step when it is after the last step of the user, found tells whether there is one.
+func (d *GormAuthDriver) updateStep(db *gorm.DB, userId string, step int64) (used, found bool, err error) {
+	result := db.Exec("UPDATE "+quote(db, d.StepTable)+" SET last_step = ? WHERE user_id = ? AND last_step < ?", step, userId, step)
+	if result.Error != nil {
+		return false, false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, true, nil
+	}
+
+	var count int
+	err = db.Table(d.StepTable).Where("user_id = ?", userId).Count(&count).Error
+	return false, count > 0, err
+}
+
+// CreateStepTable creates the TOTP steps table if it does not exist.
+func (d *GormAuthDriver) CreateStepTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	if db.Dialect().HasTable(d.StepTable) {
+		return nil
+	}
+	return db.Exec("CREATE TABLE " + quote(db, d.StepTable) + " (" +
+		"user_id VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		"last_step BIGINT NOT NULL)").Error
+}
+
+func (d *GormAuthDriver) stepDb() (*gorm.DB, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.stepsCreated.ensure(d.CreateStepTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// RecoveryCodes returns the hashes of the unused recovery codes of the user.
+func (d *GormAuthDriver) RecoveryCodes(userId string) ([]string, error) {
+	db, err := d.recoveryDb()
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	err = db.Table(d.RecoveryTable).Where("user_id = ?", userId).Pluck("code_hash", &hashes).Error
+	return hashes, err
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user with the hashes.
+func (d *GormAuthDriver) SetRecoveryCodes(userId string, hashes []string) error {
+	db, err := d.recoveryDb()
+	if err != nil {
+		return err
+	}
+
+	table := quote(db, d.RecoveryTable)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM "+table+" WHERE user_id = ?", userId).Error; err != nil {
+			return err
+		}
+		for _, hash := range hashes {
+			if err := tx.Exec("INSERT INTO "+table+" (user_id, code_hash) VALUES (?, ?)", userId, hash).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UseRecoveryCode removes a recovery code hash of the user, and returns false when it was removed before.
+func (d *GormAuthDriver) UseRecoveryCode(userId, hash string) (bool, error) {
+	db, err := d.recoveryDb()
+	if err != nil {
+		return false, err
+	}
+	result := db.Exec("DELETE FROM "+quote(db, d.RecoveryTable)+" WHERE user_id = ? AND code_hash = ?", userId, hash)
+	return result.Error == nil && result.RowsAffected > 0, result.Error
+}
+
+// CreateRecoveryTable creates the recovery codes table if it does not exist.
+func (d *GormAuthDriver) CreateRecoveryTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	if db.Dialect().HasTable(d.RecoveryTable) {
+		return nil
+	}
+	return db.Exec("CREATE TABLE " + quote(db, d.RecoveryTable) + " (" +
+		"user_id VARCHAR(255) NOT NULL, " +
+		"code_hash VARCHAR(255) NOT NULL, " +
+		"PRIMARY KEY (user_id, code_hash))").Error
+}
+
+func (d *GormAuthDriver) recoveryDb() (*gorm.DB, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.recoveryCreated.ensure(d.CreateRecoveryTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
@@ -0,0 +1,222 @@
+// Package gorpauth is an auth/basic StorageDriver keeping users in a table of the gorp module database.
+package gorpauth
+
+import (
+	"errors"
+	"sync"
+
+	sq "github.com/Masterminds/squirrel"
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/apikey"
+	gorp "github.com/revel/modules/orm/gorp/app"
+	"github.com/revel/revel"
+)
+
+// GorpAuthDriver stores the UserId and HashedSecret of auth.UserAuth users.
+//
+//	revel.OnAppStart(func() {
+//		auth.Store = gorpauth.NewGorpAuthDriver()
+//	})
+type GorpAuthDriver struct {
+	// Db is the database holding the table, gorp.Db when nil.
+	Db           *gorp.DbGorp
+	Table        string
+	UserIdColumn string
+	SecretColumn string
+	// TokenTable holds the tokens used with auth.VerifyToken.
+	TokenTable string
+	// KeyTable holds the API keys of the apikey package.
+	KeyTable string
+	// StepTable holds the last TOTP time step accepted per user, for the twofactor package.
+	StepTable string
+	// RecoveryTable holds the hashed recovery codes of the twofactor package.
+	RecoveryTable string
+	// AutoCreate creates the tables on first use when they do not exist.
+	AutoCreate bool
+
+	created         autoTable
+	tokensCreated   autoTable
+	keysCreated     autoTable
+	stepsCreated    autoTable
+	recoveryCreated autoTable
+}
+
+// NewGorpAuthDriver returns a driver on gorp.Db configured from app.conf.
+func NewGorpAuthDriver() *GorpAuthDriver {
+	d := &GorpAuthDriver{
+		Table:         "auth_users",
+		UserIdColumn:  "user_id",
+		SecretColumn:  "hashed_secret",
+		TokenTable:    "auth_tokens",
+		KeyTable:      "auth_api_keys",
+		StepTable:     "auth_totp_steps",
+		RecoveryTable: "auth_recovery_codes",
+		AutoCreate:    true,
+	}
+	if revel.Config != nil {
+		d.Table = revel.Config.StringDefault("auth.store.table", d.Table)
+		d.UserIdColumn = revel.Config.StringDefault("auth.store.userid", d.UserIdColumn)
+		d.SecretColumn = revel.Config.StringDefault("auth.store.secret", d.SecretColumn)
+		d.TokenTable = revel.Config.StringDefault("auth.token.table", d.TokenTable)
+		d.KeyTable = revel.Config.StringDefault("auth.apikey.table", d.KeyTable)
+		d.StepTable = revel.Config.StringDefault("auth.2fa.step.table", d.StepTable)
+		d.RecoveryTable = revel.Config.StringDefault("auth.2fa.recovery.table", d.RecoveryTable)
+		d.AutoCreate = revel.Config.BoolDefault("auth.store.autocreate", d.AutoCreate)
+	}
+	return d
+}
+
+// Save inserts or updates the user, or the *apikey.Key. Users without HashedSecret() get their Secret() hashed first.
+func (d *GorpAuthDriver) Save(user interface{}) (err error) {
+	if key, ok := user.(*apikey.Key); ok {
+		return d.saveKey(key)
+	}
+	u, ok := user.(auth.UserAuth)
+	if !ok {
+		return errors.New("GorpAuthDriver.Save() expected arg of type auth.UserAuth or *apikey.Key")
+	}
+	hash := u.HashedSecret()
+	if hash == "" {
+		if hash, err = u.HashSecret(u.Secret()); err != nil {
+			return err
+		}
+	}
+
+	db, err := d.db()
+	if err != nil {
+		return err
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	dialect := db.Map.Dialect
+	result, err := txn.ExecUpdate(txn.Builder().
+		Update(d.table(db)).
+		Set(dialect.QuoteField(d.SecretColumn), hash).
+		Where(sq.Eq{dialect.QuoteField(d.UserIdColumn): u.UserId()}))
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+		return err
+	}
+
+	_, err = txn.ExecInsert(txn.Builder().
+		Insert(d.table(db)).
+		Columns(dialect.QuoteField(d.UserIdColumn), dialect.QuoteField(d.SecretColumn)).
+		Values(u.UserId(), hash))
+	return err
+}
+
+// Load fills in the HashedSecret() of the user with the given UserId(), or the *apikey.Key with the given Prefix.
+// It returns auth.ErrUserNotFound or apikey.ErrKeyNotFound when there is no such user or key.
+func (d *GorpAuthDriver) Load(user interface{}) error {
+	if key, ok := user.(*apikey.Key); ok {
+		return d.loadKey(key)
+	}
+	u, ok := user.(auth.UserAuth)
+	if !ok {
+		return errors.New("GorpAuthDriver.Load() expected arg of type auth.UserAuth or *apikey.Key")
+	}
+
+	db, err := d.db()
+	if err != nil {
+		return err
+	}
+	query, args, err := db.Builder().
+		Select(db.Map.Dialect.QuoteField(d.SecretColumn)).
+		From(d.table(db)).
+		Where(sq.Eq{db.Map.Dialect.QuoteField(d.UserIdColumn): u.UserId()}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	hash, err := db.Map.SelectNullStr(query, args...)
+	if err != nil {
+		return err
+	}
+	if !hash.Valid {
+		return auth.ErrUserNotFound
+	}
+	u.SetHashedSecret(hash.String)
+	return nil
+}
+
+// CreateTable creates the user table if it does not exist.
+func (d *GorpAuthDriver) CreateTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	dialect := db.Map.Dialect
+	_, err = db.Map.Exec(dialect.IfTableNotExists("CREATE TABLE", db.Schema(), d.Table) + " " + d.table(db) + " (" +
+		dialect.QuoteField(d.UserIdColumn) + " VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		dialect.QuoteField(d.SecretColumn) + " VARCHAR(255) NOT NULL)")
+	return err
+}
+
+// db returns the database, creating the table on first use when AutoCreate is set.
+func (d *GorpAuthDriver) db() (*gorp.DbGorp, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.created.ensure(d.CreateTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+func (d *GorpAuthDriver) conn() (*gorp.DbGorp, error) {
+	return database(d.Db)
+}
+
+func (d *GorpAuthDriver) table(db *gorp.DbGorp) string {
+	return quotedTable(db, d.Table)
+}
+
+// database returns db, or gorp.Db when nil.
+func database(db *gorp.DbGorp) (*gorp.DbGorp, error) {
+	if db == nil {
+		db = gorp.Db
+	}
+	if db.Map == nil {
+		return nil, errors.New("gorp module database not initialized")
+	}
+	return db, nil
+}
+
+func quotedTable(db *gorp.DbGorp, table string) string {
+	return db.Map.Dialect.QuotedTableForQuery(db.Schema(), table)
+}
+
+// autoTable creates a table once, on first use.
+type autoTable struct {
+	lock  sync.Mutex
+	ready bool
+}
+
+func (t *autoTable) ensure(create func() error) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.ready {
+		return nil
+	}
+	if err := create(); err != nil {
+		return err
+	}
+	t.ready = true
+	return nil
+}
@@ -0,0 +1,149 @@
+package gorpauth
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/revel/modules/auth/basic/apikey"
+	gorp "github.com/revel/modules/orm/gorp/app"
+)
+
+// keyRow is a row of the API keys table.
+type keyRow struct {
+	Prefix    string `db:"prefix"`
+	Hash      string `db:"hash"`
+	UserId    string `db:"user_id"`
+	Name      string `db:"name"`
+	Scopes    string `db:"scopes"`
+	CreatedAt int64  `db:"created_at"`
+	LastUsed  int64  `db:"last_used"`
+	RevokedAt int64  `db:"revoked_at"`
+}
+
+func (d *GorpAuthDriver) saveKey(key *apikey.Key) (err error) {
+	db, err := d.keyDb()
+	if err != nil {
+		return err
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	table := quotedTable(db, d.KeyTable)
+	columns := sq.Eq{
+		"hash":       key.Hash,
+		"user_id":    key.UserId,
+		"name":       key.Name,
+		"scopes":     strings.Join(key.Scopes, " "),
+		"created_at": unix(key.CreatedAt),
+		"last_used":  unix(key.LastUsed),
+		"revoked_at": unix(key.RevokedAt),
+	}
+	result, err := txn.ExecUpdate(txn.Builder().Update(table).SetMap(columns).Where(sq.Eq{"prefix": key.Prefix}))
+	if err != nil {
+		return err
+	}
+	if updated, err := result.RowsAffected(); err != nil || updated > 0 {
+		return err
+	}
+
+	columns["prefix"] = key.Prefix
+	_, err = txn.ExecInsert(txn.Builder().Insert(table).SetMap(columns))
+	return err
+}
+
+func (d *GorpAuthDriver) loadKey(key *apikey.Key) error {
+	db, err := d.keyDb()
+	if err != nil {
+		return err
+	}
+	query, args, err := db.Builder().
+		Select("prefix", "hash", "user_id", "name", "scopes", "created_at", "last_used", "revoked_at").
+		From(quotedTable(db, d.KeyTable)).
+		Where(sq.Eq{"prefix": key.Prefix}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	var row keyRow
+	if err := db.Map.SelectOne(&row, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			return apikey.ErrKeyNotFound
+		}
+		return err
+	}
+	key.Prefix, key.Hash, key.UserId, key.Name = row.Prefix, row.Hash, row.UserId, row.Name
+	key.Scopes = strings.Fields(row.Scopes)
+	key.CreatedAt, key.LastUsed, key.RevokedAt = fromUnix(row.CreatedAt), fromUnix(row.LastUsed), fromUnix(row.RevokedAt)
+	return nil
+}
+
+// UseKey sets the LastUsed time of the key with the prefix, unless the key is revoked.
+func (d *GorpAuthDriver) UseKey(prefix string, at time.Time) error {
+	db, err := d.keyDb()
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecUpdate(db.Builder().
+		Update(quotedTable(db, d.KeyTable)).
+		Set("last_used", unix(at)).
+		Where(sq.Eq{"prefix": prefix, "revoked_at": 0}))
+	return err
+}
+
+// CreateKeyTable creates the API keys table if it does not exist.
+func (d *GorpAuthDriver) CreateKeyTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	_, err = db.Map.Exec(db.Map.Dialect.IfTableNotExists("CREATE TABLE", db.Schema(), d.KeyTable) + " " + quotedTable(db, d.KeyTable) + " (" +
+		"prefix VARCHAR(64) NOT NULL PRIMARY KEY, " +
+		"hash VARCHAR(255) NOT NULL, " +
+		"user_id VARCHAR(255) NOT NULL, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"scopes VARCHAR(1024) NOT NULL, " +
+		"created_at BIGINT NOT NULL, " +
+		"last_used BIGINT NOT NULL, " +
+		"revoked_at BIGINT NOT NULL)")
+	return err
+}
+
+func (d *GorpAuthDriver) keyDb() (*gorp.DbGorp, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.keysCreated.ensure(d.CreateKeyTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// unix returns the unix time of t, 0 for the zero time.
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
@@ -0,0 +1,60 @@
+package gorpauth
+
+import (
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// UseToken marks a token used and returns false if it was used before.
+func (d *GorpAuthDriver) UseToken(id string, expires time.Time) (fresh bool, err error) {
+	db, err := d.conn()
+	if err != nil {
+		return
+	}
+	if d.AutoCreate {
+		if err = d.tokensCreated.ensure(d.CreateTokenTable); err != nil {
+			return
+		}
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+			fresh = false
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	table := quotedTable(db, d.TokenTable)
+	query, args, err := txn.Builder().Delete(table).Where(sq.Lt{"expires": time.Now().Unix()}).ToSql()
+	if err != nil {
+		return
+	}
+	if _, err = txn.Map.Exec(query, args...); err != nil {
+		return
+	}
+
+	used, err := txn.SelectInt(txn.Builder().Select("COUNT(*)").From(table).Where(sq.Eq{"token_id": id}))
+	if err != nil || used > 0 {
+		return
+	}
+	_, err = txn.ExecInsert(txn.Builder().Insert(table).Columns("token_id", "expires").Values(id, expires.Unix()))
+	return err == nil, err
+}
+
+// CreateTokenTable creates the used tokens table if it does not exist.
+func (d *GorpAuthDriver) CreateTokenTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	_, err = db.Map.Exec(db.Map.Dialect.IfTableNotExists("CREATE TABLE", db.Schema(), d.TokenTable) + " " + quotedTable(db, d.TokenTable) + " (" +
+		"token_id VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		"expires BIGINT NOT NULL)")
+	return err
+}
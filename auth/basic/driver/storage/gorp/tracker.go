@@ -0,0 +1,189 @@
+package gorpauth
+
+import (
+	"database/sql"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	gorp "github.com/revel/modules/orm/gorp/app"
+	"github.com/revel/revel"
+)
+
+// GorpAttemptTracker is an auth.AttemptTracker keeping the failed logins in a table,
+// shared by all the app servers.
+//
+//	revel.OnAppStart(func() {
+//		auth.Tracker = gorpauth.NewGorpAttemptTracker()
+//	})
+type GorpAttemptTracker struct {
+	// Db is the database holding the table, gorp.Db when nil.
+	Db    *gorp.DbGorp
+	Table string
+	// AutoCreate creates the table on first use when it does not exist.
+	AutoCreate bool
+
+	created autoTable
+}
+
+// NewGorpAttemptTracker returns a tracker on gorp.Db configured from app.conf.
+func NewGorpAttemptTracker() *GorpAttemptTracker {
+	t := &GorpAttemptTracker{
+		Table:      "auth_attempts",
+		AutoCreate: true,
+	}
+	if revel.Config != nil {
+		t.Table = revel.Config.StringDefault("auth.throttle.table", t.Table)
+		t.AutoCreate = revel.Config.BoolDefault("auth.store.autocreate", t.AutoCreate)
+	}
+	return t
+}
+
+// Failures returns the number of failures recorded for the key and the time of the last one.
+func (t *GorpAttemptTracker) Failures(key string) (count int, last time.Time, err error) {
+	db, err := t.db()
+	if err != nil {
+		return
+	}
+	return t.load(db, quotedTable(db, t.Table), key)
+}
+
+// AddFailure records a failure for the key and returns the updated count.
+// The count is incremented in place, so the failures of concurrent logins are all counted.
+func (t *GorpAttemptTracker) AddFailure(key string, at time.Time, window time.Duration) (count int, err error) {
+	db, err := t.db()
+	if err != nil {
+		return
+	}
+
+	count, found, err := t.increment(db, key, at, window)
+	if err != nil || found {
+		return
+	}
+	_, err = db.ExecInsert(db.Builder().
+		Insert(quotedTable(db, t.Table)).
+		Columns("attempt_key", "failures", "last_failure").
+		Values(key, 1, at.UnixNano()))
+	if err == nil {
+		return 1, nil
+	}
+
+	// another server inserted the key first, count the failure on its row
+	if count, found, _ = t.increment(db, key, at, window); found {
+		return count, nil
+	}
+	return 0, err
+}
+
+// increment adds a failure to the row of the key when there is one, and reads the count
+// in the same transaction, while the row is locked by the update.
+func (t *GorpAttemptTracker) increment(db *gorp.DbGorp, key string, at time.Time, window time.Duration) (count int, found bool, err error) {
+	txn, err := db.Begin()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	result, err := txn.ExecUpdate(txn.Builder().
+		Update(quotedTable(db, t.Table)).
+		Set("failures", sq.Expr("CASE WHEN last_failure < ? THEN 1 ELSE failures + 1 END", at.Add(-window).UnixNano())).
+		Set("last_failure", at.UnixNano()).
+		Where(sq.Eq{"attempt_key": key}))
+	if err != nil {
+		return
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		return 0, false, err
+	}
+	count, _, err = t.load(txn, quotedTable(db, t.Table), key)
+	return count, err == nil, err
+}
+
+// RemoveFailure takes back one failure of the key.
+func (t *GorpAttemptTracker) RemoveFailure(key string) error {
+	db, err := t.db()
+	if err != nil {
+		return err
+	}
+	query, args, err := db.Builder().
+		Update(quotedTable(db, t.Table)).
+		Set("failures", sq.Expr("failures - 1")).
+		Where(sq.Eq{"attempt_key": key}).
+		Where(sq.Gt{"failures": 0}).
+		ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = db.Map.Exec(query, args...)
+	return err
+}
+
+// Reset forgets the failures of the key.
+func (t *GorpAttemptTracker) Reset(key string) error {
+	db, err := t.db()
+	if err != nil {
+		return err
+	}
+	query, args, err := db.Builder().Delete(quotedTable(db, t.Table)).Where(sq.Eq{"attempt_key": key}).ToSql()
+	if err != nil {
+		return err
+	}
+	_, err = db.Map.Exec(query, args...)
+	return err
+}
+
+// CreateTable creates the attempts table if it does not exist.
+func (t *GorpAttemptTracker) CreateTable() error {
+	db, err := database(t.Db)
+	if err != nil {
+		return err
+	}
+	_, err = db.Map.Exec(db.Map.Dialect.IfTableNotExists("CREATE TABLE", db.Schema(), t.Table) + " " + quotedTable(db, t.Table) + " (" +
+		"attempt_key VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		"failures INTEGER NOT NULL, " +
+		"last_failure BIGINT NOT NULL)")
+	return err
+}
+
+// load reads the failures of the key from the table through db or a transaction.
+func (t *GorpAttemptTracker) load(db gorp.DbReadable, table, key string) (count int, last time.Time, err error) {
+	query, args, err := db.Builder().
+		Select("failures", "last_failure").
+		From(table).
+		Where(sq.Eq{"attempt_key": key}).
+		ToSql()
+	if err != nil {
+		return
+	}
+
+	var row struct {
+		Failures    int   `db:"failures"`
+		LastFailure int64 `db:"last_failure"`
+	}
+	if err = db.GetMap().SelectOne(&row, query, args...); err != nil {
+		if err == sql.ErrNoRows {
+			err = nil
+		}
+		return
+	}
+	return row.Failures, time.Unix(0, row.LastFailure), nil
+}
+
+// db returns the database, creating the table on first use when AutoCreate is set.
+func (t *GorpAttemptTracker) db() (*gorp.DbGorp, error) {
+	db, err := database(t.Db)
+	if err != nil {
+		return nil, err
+	}
+	if t.AutoCreate {
+		if err := t.created.ensure(t.CreateTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
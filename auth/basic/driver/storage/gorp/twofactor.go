@@ -0,0 +1,181 @@
+package gorpauth
+
+import (
+	sq "github.com/Masterminds/squirrel"
+	gorp "github.com/revel/modules/orm/gorp/app"
+)
+
+// UseStep records step as the last TOTP time step accepted for the user,
+// and returns false when it is not after the last step recorded.
+func (d *GorpAuthDriver) UseStep(userId string, step int64) (bool, error) {
+	db, err := d.stepDb()
+	if err != nil {
+		return false, err
+	}
+
+	used, found, err := d.updateStep(db, userId, step)
+	if err != nil || found {
+		return used, err
+	}
+	_, err = db.ExecInsert(db.Builder().
+		Insert(quotedTable(db, d.StepTable)).
+		Columns("user_id", "last_step").
+		Values(userId, step))
+	if err == nil {
+		return true, nil
+	}
+
+	// another server recorded a step of the user first
+	if used, found, _ = d.updateStep(db, userId, step); found {
+		return used, nil
+	}
+	return false, err
+}
+
+// updateStep records the step when it is after the last step of the user, found tells whether there is one.
+func (d *GorpAuthDriver) updateStep(db *gorp.DbGorp, userId string, step int64) (used, found bool, err error) {
+	table := quotedTable(db, d.StepTable)
+	result, err := db.ExecUpdate(db.Builder().
+		Update(table).
+		Set("last_step", step).
+		Where(sq.Eq{"user_id": userId}).
+		Where(sq.Lt{"last_step": step}))
+	if err != nil {
+		return
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return
+	}
+	if updated > 0 {
+		return true, true, nil
+	}
+
+	count, err := db.SelectInt(db.Builder().Select("COUNT(*)").From(table).Where(sq.Eq{"user_id": userId}))
+	return false, count > 0, err
+}
+
+// CreateStepTable creates the TOTP steps table if it does not exist.
+func (d *GorpAuthDriver) CreateStepTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	_, err = db.Map.Exec(db.Map.Dialect.IfTableNotExists("CREATE TABLE", db.Schema(), d.StepTable) + " " + quotedTable(db, d.StepTable) + " (" +
+		"user_id VARCHAR(255) NOT NULL PRIMARY KEY, " +
+		"last_step BIGINT NOT NULL)")
+	return err
+}
+
+func (d *GorpAuthDriver) stepDb() (*gorp.DbGorp, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.stepsCreated.ensure(d.CreateStepTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// RecoveryCodes returns the hashes of the unused recovery codes of the user.
+func (d *GorpAuthDriver) RecoveryCodes(userId string) ([]string, error) {
+	db, err := d.recoveryDb()
+	if err != nil {
+		return nil, err
+	}
+	query, args, err := db.Builder().
+		Select("code_hash").
+		From(quotedTable(db, d.RecoveryTable)).
+		Where(sq.Eq{"user_id": userId}).
+		ToSql()
+	if err != nil {
+		return nil, err
+	}
+	var hashes []string
+	_, err = db.Map.Select(&hashes, query, args...)
+	return hashes, err
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user with the hashes.
+func (d *GorpAuthDriver) SetRecoveryCodes(userId string, hashes []string) (err error) {
+	db, err := d.recoveryDb()
+	if err != nil {
+		return err
+	}
+	txn, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = txn.Rollback()
+			return
+		}
+		err = txn.Commit()
+	}()
+
+	table := quotedTable(db, d.RecoveryTable)
+	query, args, err := txn.Builder().Delete(table).Where(sq.Eq{"user_id": userId}).ToSql()
+	if err != nil {
+		return err
+	}
+	if _, err = txn.Map.Exec(query, args...); err != nil {
+		return err
+	}
+	for _, hash := range hashes {
+		if _, err = txn.ExecInsert(txn.Builder().Insert(table).Columns("user_id", "code_hash").Values(userId, hash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode removes a recovery code hash of the user, and returns false when it was removed before.
+func (d *GorpAuthDriver) UseRecoveryCode(userId, hash string) (bool, error) {
+	db, err := d.recoveryDb()
+	if err != nil {
+		return false, err
+	}
+	query, args, err := db.Builder().
+		Delete(quotedTable(db, d.RecoveryTable)).
+		Where(sq.Eq{"user_id": userId, "code_hash": hash}).
+		ToSql()
+	if err != nil {
+		return false, err
+	}
+	result, err := db.Map.Exec(query, args...)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
+
+// CreateRecoveryTable creates the recovery codes table if it does not exist.
+func (d *GorpAuthDriver) CreateRecoveryTable() error {
+	db, err := d.conn()
+	if err != nil {
+		return err
+	}
+	_, err = db.Map.Exec(db.Map.Dialect.IfTableNotExists("CREATE TABLE", db.Schema(), d.RecoveryTable) + " " + quotedTable(db, d.RecoveryTable) + " (" +
+		"user_id VARCHAR(255) NOT NULL, " +
+		"code_hash VARCHAR(255) NOT NULL, " +
+		"PRIMARY KEY (user_id, code_hash))")
+	return err
+}
+
+func (d *GorpAuthDriver) recoveryDb() (*gorp.DbGorp, error) {
+	db, err := d.conn()
+	if err != nil {
+		return nil, err
+	}
+	if d.AutoCreate {
+		if err := d.recoveryCreated.ensure(d.CreateRecoveryTable); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
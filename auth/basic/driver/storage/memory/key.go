@@ -0,0 +1,42 @@
+package memoryauth
+
+import (
+	"time"
+
+	"github.com/revel/modules/auth/basic/apikey"
+)
+
+func (d *MemoryAuthDriver) saveKey(key *apikey.Key) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	stored := *key
+	stored.Scopes = append([]string{}, key.Scopes...)
+	d.keys[key.Prefix] = stored
+	return nil
+}
+
+func (d *MemoryAuthDriver) loadKey(key *apikey.Key) error {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	stored, found := d.keys[key.Prefix]
+	if !found {
+		return apikey.ErrKeyNotFound
+	}
+	*key = stored
+	key.Scopes = append([]string{}, stored.Scopes...)
+	return nil
+}
+
+// UseKey sets the LastUsed time of the key with the prefix, unless the key is revoked.
+func (d *MemoryAuthDriver) UseKey(prefix string, at time.Time) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if stored, found := d.keys[prefix]; found && !stored.Revoked() {
+		stored.LastUsed = at
+		d.keys[prefix] = stored
+	}
+	return nil
+}
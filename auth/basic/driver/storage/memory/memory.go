@@ -0,0 +1,103 @@
+// Package memoryauth is an auth/basic StorageDriver keeping users in memory, for tests and prototypes.
+package memoryauth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/apikey"
+)
+
+// MemoryAuthDriver stores the UserId and HashedSecret of auth.UserAuth users, and API keys, in maps.
+// Users are lost when the application stops.
+type MemoryAuthDriver struct {
+	lock     sync.RWMutex
+	users    map[string]string
+	tokens   map[string]time.Time
+	keys     map[string]apikey.Key
+	steps    map[string]int64
+	recovery map[string][]string
+}
+
+// NewMemoryAuthDriver returns an empty driver.
+func NewMemoryAuthDriver() *MemoryAuthDriver {
+	return &MemoryAuthDriver{
+		users:    map[string]string{},
+		tokens:   map[string]time.Time{},
+		keys:     map[string]apikey.Key{},
+		steps:    map[string]int64{},
+		recovery: map[string][]string{},
+	}
+}
+
+// Save inserts or updates the user, or the *apikey.Key. Users without HashedSecret() get their Secret() hashed first.
+func (d *MemoryAuthDriver) Save(user interface{}) error {
+	if key, ok := user.(*apikey.Key); ok {
+		return d.saveKey(key)
+	}
+	u, ok := user.(auth.UserAuth)
+	if !ok {
+		return errors.New("MemoryAuthDriver.Save() expected arg of type auth.UserAuth or *apikey.Key")
+	}
+	hash := u.HashedSecret()
+	if hash == "" {
+		var err error
+		if hash, err = u.HashSecret(u.Secret()); err != nil {
+			return err
+		}
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.users[u.UserId()] = hash
+	return nil
+}
+
+// Load fills in the HashedSecret() of the user with the given UserId(), or the *apikey.Key with the given Prefix.
+// It returns auth.ErrUserNotFound or apikey.ErrKeyNotFound when there is no such user or key.
+func (d *MemoryAuthDriver) Load(user interface{}) error {
+	if key, ok := user.(*apikey.Key); ok {
+		return d.loadKey(key)
+	}
+	u, ok := user.(auth.UserAuth)
+	if !ok {
+		return errors.New("MemoryAuthDriver.Load() expected arg of type auth.UserAuth or *apikey.Key")
+	}
+
+	d.lock.RLock()
+	hash, found := d.users[u.UserId()]
+	d.lock.RUnlock()
+	if !found {
+		return auth.ErrUserNotFound
+	}
+	u.SetHashedSecret(hash)
+	return nil
+}
+
+// Delete removes the user with the given id.
+func (d *MemoryAuthDriver) Delete(userId string) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.users, userId)
+}
+
+// UseToken marks a token used and returns false if it was used before.
+func (d *MemoryAuthDriver) UseToken(id string, expires time.Time) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	for usedId, usedExpires := range d.tokens {
+		if now.After(usedExpires) {
+			delete(d.tokens, usedId)
+		}
+	}
+
+	if _, used := d.tokens[id]; used {
+		return false, nil
+	}
+	d.tokens[id] = expires
+	return true, nil
+}
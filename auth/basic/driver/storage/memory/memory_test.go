@@ -0,0 +1,162 @@
+package memoryauth
+
+import (
+	"testing"
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/apikey"
+	"github.com/revel/modules/auth/basic/driver/secret"
+	"github.com/revel/modules/auth/basic/twofactor"
+)
+
+type User struct {
+	email    string
+	password string
+	hashpass string
+
+	secret.BcryptAuth
+}
+
+func NewUser(email, pass string) *User {
+	u := &User{email: email, password: pass}
+	u.UserContext = u
+	return u
+}
+
+func (u *User) UserId() string               { return u.email }
+func (u *User) Secret() string               { return u.password }
+func (u *User) HashedSecret() string         { return u.hashpass }
+func (u *User) SetHashedSecret(hpass string) { u.hashpass = hpass }
+func (u *User) TOTPSecret() string           { return "" }
+
+func TestMemoryAuthDriver(t *testing.T) {
+	store := NewMemoryAuthDriver()
+	auth.Store = store
+
+	if err := store.Load(NewUser("demo@domain.com", "")); err != auth.ErrUserNotFound {
+		t.Fatalf("Should not have found user, got %v", err)
+	}
+	if err := store.Save(NewUser("demo@domain.com", "demopass")); err != nil {
+		t.Fatalf("Should have saved user: %v", err)
+	}
+
+	u := NewUser("demo@domain.com", "demopass")
+	if err := store.Load(u); err != nil {
+		t.Fatalf("Should have loaded user: %v", err)
+	}
+	if ok, err := u.Authenticate(); !ok || err != nil {
+		t.Errorf("Should have authenticated user: %v", err)
+	}
+
+	store.Delete("demo@domain.com")
+	if err := store.Load(u); err != auth.ErrUserNotFound {
+		t.Errorf("Should have deleted user, got %v", err)
+	}
+}
+
+func TestUseToken(t *testing.T) {
+	store := NewMemoryAuthDriver()
+
+	if fresh, err := store.UseToken("reset:expired", time.Now().Add(-time.Minute)); !fresh || err != nil {
+		t.Fatalf("Should have accepted a new token, got %v %v", fresh, err)
+	}
+	if fresh, err := store.UseToken("reset:1", time.Now().Add(time.Hour)); !fresh || err != nil {
+		t.Fatalf("Should have accepted a new token, got %v %v", fresh, err)
+	}
+	if fresh, err := store.UseToken("reset:1", time.Now().Add(time.Hour)); fresh || err != nil {
+		t.Fatalf("Should have refused a used token, got %v %v", fresh, err)
+	}
+	// expired tokens are forgotten, their signature is refused anyway
+	if fresh, err := store.UseToken("reset:expired", time.Now().Add(time.Hour)); !fresh || err != nil {
+		t.Fatalf("Should have forgotten the expired token, got %v %v", fresh, err)
+	}
+}
+
+func TestUseStep(t *testing.T) {
+	store := NewMemoryAuthDriver()
+
+	if used, err := store.UseStep("demo@domain.com", 100); !used || err != nil {
+		t.Fatalf("Should have accepted the first step, got %v %v", used, err)
+	}
+	if used, err := store.UseStep("demo@domain.com", 100); used || err != nil {
+		t.Fatalf("Should have refused a used step, got %v %v", used, err)
+	}
+	if used, err := store.UseStep("demo@domain.com", 99); used || err != nil {
+		t.Fatalf("Should have refused an earlier step, got %v %v", used, err)
+	}
+	if used, err := store.UseStep("demo@domain.com", 101); !used || err != nil {
+		t.Fatalf("Should have accepted a later step, got %v %v", used, err)
+	}
+	if used, err := store.UseStep("other@domain.com", 100); !used || err != nil {
+		t.Fatalf("Should have kept the steps per user, got %v %v", used, err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	store := NewMemoryAuthDriver()
+	auth.Store = store
+	defer func(tracker auth.AttemptTracker) { auth.Tracker = tracker }(auth.Tracker)
+	auth.Tracker = nil
+	// keep the code hashing fast
+	defer func(params secret.Argon2Params) { secret.DefaultArgon2Params = params }(secret.DefaultArgon2Params)
+	secret.DefaultArgon2Params.Memory, secret.DefaultArgon2Params.Time = 1024, 1
+	u := NewUser("demo@domain.com", "demopass")
+
+	codes, err := twofactor.GenerateRecoveryCodes(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := twofactor.VerifyCode(u, codes[0]); !ok || err != nil {
+		t.Fatalf("Should have accepted the recovery code, got %v %v", ok, err)
+	}
+	if ok, err := twofactor.VerifyCode(u, codes[0]); ok || err != nil {
+		t.Fatalf("Should have refused the used recovery code, got %v %v", ok, err)
+	}
+	if hashes, err := store.RecoveryCodes(u.UserId()); len(hashes) != len(codes)-1 || err != nil {
+		t.Errorf("Should have kept the unused codes, got %d %v", len(hashes), err)
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	store := NewMemoryAuthDriver()
+
+	if err := store.Load(&apikey.Key{Prefix: "rvl_1"}); err != apikey.ErrKeyNotFound {
+		t.Fatalf("Should not have found the key, got %v", err)
+	}
+
+	created := time.Unix(1600000000, 0)
+	key := &apikey.Key{Prefix: "rvl_1", Hash: "hash", UserId: "demo@domain.com", Name: "CI", Scopes: []string{"a", "b"}, CreatedAt: created}
+	if err := store.Save(key); err != nil {
+		t.Fatalf("Should have inserted the key: %v", err)
+	}
+	key.RevokedAt = created.Add(time.Hour)
+	if err := store.Save(key); err != nil {
+		t.Fatalf("Should have updated the key: %v", err)
+	}
+	// the use of a key revoked meanwhile is not recorded, the key stays revoked
+	if err := store.UseKey("rvl_1", created.Add(2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &apikey.Key{Prefix: "rvl_1"}
+	if err := store.Load(loaded); err != nil {
+		t.Fatalf("Should have loaded the key: %v", err)
+	}
+	if loaded.UserId != key.UserId || loaded.Name != key.Name || len(loaded.Scopes) != 2 || loaded.Scopes[1] != "b" ||
+		!loaded.CreatedAt.Equal(created) || !loaded.LastUsed.IsZero() || !loaded.RevokedAt.Equal(key.RevokedAt) {
+		t.Errorf("Should have loaded the saved key, got %#v", loaded)
+	}
+
+	key = &apikey.Key{Prefix: "rvl_2", Hash: "hash", UserId: "demo@domain.com", CreatedAt: created}
+	if err := store.Save(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UseKey("rvl_2", created.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	loaded = &apikey.Key{Prefix: "rvl_2"}
+	if err := store.Load(loaded); err != nil || !loaded.LastUsed.Equal(created.Add(time.Hour)) {
+		t.Errorf("Should have recorded the use of the key, got %v %v", loaded.LastUsed, err)
+	}
+}
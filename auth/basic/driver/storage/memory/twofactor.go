@@ -0,0 +1,44 @@
+package memoryauth
+
+// UseStep records step as the last TOTP time step accepted for the user,
+// and returns false when it is not after the last step recorded.
+func (d *MemoryAuthDriver) UseStep(userId string, step int64) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if last, found := d.steps[userId]; found && step <= last {
+		return false, nil
+	}
+	d.steps[userId] = step
+	return true, nil
+}
+
+// RecoveryCodes returns the hashes of the unused recovery codes of the user.
+func (d *MemoryAuthDriver) RecoveryCodes(userId string) ([]string, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return append([]string{}, d.recovery[userId]...), nil
+}
+
+// SetRecoveryCodes replaces the recovery codes of the user with the hashes.
+func (d *MemoryAuthDriver) SetRecoveryCodes(userId string, hashes []string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.recovery[userId] = append([]string{}, hashes...)
+	return nil
+}
+
+// UseRecoveryCode removes a recovery code hash of the user, and returns false when it was removed before.
+func (d *MemoryAuthDriver) UseRecoveryCode(userId, hash string) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	hashes := d.recovery[userId]
+	for i, stored := range hashes {
+		if stored == hash {
+			d.recovery[userId] = append(append([]string{}, hashes[:i]...), hashes[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/revel/revel"
+)
+
+// UserArgKey is the controller Args key holding the authenticated UserAuth.
+const UserArgKey = "auth.user"
+
+// SecondFactorUser is implemented by users who may have a second factor, like twofactor.User.
+type SecondFactorUser interface {
+	// TOTPSecret returns the TOTP secret of the user, empty when 2FA is not enabled.
+	TOTPSecret() string
+}
+
+// HTTPAuth authenticates requests sent with an `Authorization: Basic` header,
+// and optionally with an `Authorization: Bearer` header.
+// Basic credentials only carry the password, so they are refused for users with a second factor (see SecondFactorUser).
+//
+// Usage:
+//  1. Set auth.Store to the StorageDriver holding your users.
+//  2. Create the module with a function returning your app-level User model for the credentials:
+//     httpAuth := auth.NewHTTPAuth(func(userId, secret string) auth.UserAuth { return models.NewUser(userId, secret) })
+//  3. Add `httpAuth.AuthFilter` to the app's filters (it must come after the revel.RouterFilter).
+type HTTPAuth struct {
+	// Realm is sent in the WWW-Authenticate header, when empty the `auth.realm` setting is used.
+	Realm string
+	// NewUser returns an app-level user holding the user id and the plain text secret sent by the client,
+	// auth.NewUser is used when nil.
+	NewUser func(userId, secret string) UserAuth
+	// BearerAuth returns the user owning a bearer token, a nil user rejects the request.
+	// Bearer tokens are refused when it is not set.
+	BearerAuth func(token string) (UserAuth, error)
+
+	skip ActionSet
+}
+
+// NewHTTPAuth is the constructor for HTTPAuth.
+func NewHTTPAuth(newUser func(userId, secret string) UserAuth) *HTTPAuth {
+	return &HTTPAuth{
+		NewUser: newUser,
+	}
+}
+
+// Skip excludes actions from authentication, in the form of "ControllerName.ActionName" or "ControllerName.*".
+func (ha *HTTPAuth) Skip(actions ...string) {
+	for _, action := range actions {
+		ha.skip.Add(action)
+	}
+}
+
+// AuthFilter authenticates the request and places the user in `c.Args[auth.UserArgKey]`.
+// Requests without valid credentials are answered with 401 Unauthorized.
+func (ha *HTTPAuth) AuthFilter(c *revel.Controller, fc []revel.Filter) {
+	if ha.skip.Contains(c.Action) {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	user, err := ha.authenticate(c.Request.GetHttpHeader("Authorization"), c.ClientIP)
+	if throttled, ok := err.(*ThrottledError); ok {
+		c.Log.Warn("Authentication throttled", "error", err)
+		c.Result = tooManyRequests(c, throttled)
+		return
+	}
+	if err != nil {
+		c.Log.Warn("Authentication failed", "error", err)
+		c.Result = ha.unauthorized(c)
+		return
+	}
+
+	c.Args[UserArgKey] = user
+	fc[0](c, fc[1:])
+}
+
+// CurrentUser returns the user authenticated for the request, or the user logged in the session, or nil.
+func CurrentUser(c *revel.Controller) UserAuth {
+	if user, ok := c.Args[UserArgKey].(UserAuth); ok {
+		return user
+	}
+
+	userId := SessionUser(c)
+	if userId == "" || NewUser == nil || Store == nil {
+		return nil
+	}
+	user := NewUser(userId, "")
+	if err := Store.Load(user); err != nil {
+		c.Log.Warn("Failed to load the session user", "user", userId, "error", err)
+		return nil
+	}
+	c.Args[UserArgKey] = user
+	return user
+}
+
+func (ha *HTTPAuth) authenticate(authorization, clientIP string) (UserAuth, error) {
+	scheme, credentials := authorization, ""
+	if i := strings.Index(authorization, " "); i != -1 {
+		scheme, credentials = authorization[:i], strings.TrimSpace(authorization[i+1:])
+	}
+
+	switch {
+	case strings.EqualFold(scheme, "Basic"):
+		userId, secret, ok := parseBasicCredentials(credentials)
+		if !ok {
+			return nil, errors.New("malformed basic credentials")
+		}
+		return ha.authenticateBasic(userId, secret, clientIP)
+	case strings.EqualFold(scheme, "Bearer") && ha.BearerAuth != nil:
+		user, err := ha.BearerAuth(credentials)
+		if err == nil && user == nil {
+			err = errors.New("unknown bearer token")
+		}
+		return user, err
+	case authorization == "":
+		return nil, errors.New("no credentials")
+	}
+
+	return nil, errors.New("unsupported authorization scheme " + scheme)
+}
+
+// authenticateBasic loads the user through the Store and checks the secret with Login,
+// or with LoginUnknown when there is no such user. Users with a second factor are refused.
+func (ha *HTTPAuth) authenticateBasic(userId, secret, clientIP string) (UserAuth, error) {
+	if Store == nil {
+		return nil, errors.New("auth module StorageDriver not set")
+	}
+
+	newUser := ha.NewUser
+	if newUser == nil {
+		newUser = NewUser
+	}
+	if newUser == nil {
+		return nil, errors.New("auth module NewUser not set")
+	}
+
+	user := newUser(userId, secret)
+	if err := Store.Load(user); err != nil {
+		if err == ErrUserNotFound {
+			return nil, LoginUnknown(user, clientIP)
+		}
+		return nil, err
+	}
+	if u, ok := user.(SecondFactorUser); ok && u.TOTPSecret() != "" {
+		return nil, errors.New("basic credentials refused for a user with a second factor")
+	}
+
+	if _, err := Login(user, clientIP); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (ha *HTTPAuth) unauthorized(c *revel.Controller) revel.Result {
+	realm := ha.Realm
+	if realm == "" && revel.Config != nil {
+		realm = revel.Config.StringDefault("auth.realm", "Restricted")
+	}
+
+	c.Response.Status = http.StatusUnauthorized
+	c.Response.Out.Header().Set("WWW-Authenticate", "Basic realm=\""+realm+"\"")
+	if ha.BearerAuth != nil {
+		c.Response.Out.Header().Add("WWW-Authenticate", "Bearer realm=\""+realm+"\"")
+	}
+	return c.RenderError(errors.New("401: Not Authorized"))
+}
+
+func tooManyRequests(c *revel.Controller, throttled *ThrottledError) revel.Result {
+	c.Response.Status = http.StatusTooManyRequests
+	c.Response.Out.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+	return c.RenderError(errors.New("429: Too Many Requests"))
+}
+
+// parseBasicCredentials decodes the credentials of an `Authorization: Basic` header.
+func parseBasicCredentials(credentials string) (userId, secret string, ok bool) {
+	decoded, err := base64.StdEncoding.DecodeString(credentials)
+	if err != nil {
+		return
+	}
+
+	i := strings.Index(string(decoded), ":")
+	if i == -1 {
+		return
+	}
+	return string(decoded[:i]), string(decoded[i+1:]), true
+}
@@ -0,0 +1,151 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+	"github.com/revel/revel/logger"
+)
+
+func testHTTPAuth(t *testing.T, httpAuth *auth.HTTPAuth, action string, setup func(r *http.Request)) *revel.Controller {
+	t.Helper()
+	r, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	setup(r)
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(r)
+	context.Response.SetResponse(httptest.NewRecorder())
+	c := revel.NewController(context)
+	c.Log = logger.New("module", "test")
+	c.Action = action
+
+	filters := []revel.Filter{
+		httpAuth.AuthFilter,
+		func(c *revel.Controller, fc []revel.Filter) {
+			c.Result = c.RenderText("OK.")
+		},
+	}
+	filters[0](c, filters[1:])
+	return c
+}
+
+func newHTTPAuth(t *testing.T) *auth.HTTPAuth {
+	t.Helper()
+	auth.Store = &TestStore{
+		data: make(map[string]string),
+	}
+	auth.Tracker = auth.NewMemoryTracker()
+	if err := auth.Store.Save(NewUser("demo@domain.com", "demopass")); err != nil {
+		t.Fatalf("Should have saved user: %v", err)
+	}
+
+	httpAuth := auth.NewHTTPAuth(func(userId, secret string) auth.UserAuth {
+		return NewUser(userId, secret)
+	})
+	httpAuth.Realm = "test"
+	return httpAuth
+}
+
+func TestHTTPAuthBasic(t *testing.T) {
+	httpAuth := newHTTPAuth(t)
+
+	c := testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.SetBasicAuth("demo@domain.com", "demopass") })
+	if c.Response.Status == http.StatusUnauthorized {
+		t.Fatal("Should have authenticated user")
+	}
+	if user := auth.CurrentUser(c); user == nil || user.UserId() != "demo@domain.com" {
+		t.Fatalf("Should have placed the user on the controller, got %v", user)
+	}
+
+	c = testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.SetBasicAuth("demo@domain.com", "invalid") })
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatal("Should have rejected invalid password")
+	}
+	if header := c.Response.Out.Header().Get("Www-Authenticate"); header != `Basic realm="test"` {
+		t.Fatalf("Should have sent the realm, got %q", header)
+	}
+
+	c = testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) {})
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatal("Should have rejected request without credentials")
+	}
+}
+
+func TestHTTPAuthBearer(t *testing.T) {
+	httpAuth := newHTTPAuth(t)
+	httpAuth.BearerAuth = func(token string) (auth.UserAuth, error) {
+		if token == "secret-token" {
+			return NewUser("demo@domain.com", ""), nil
+		}
+		return nil, nil
+	}
+
+	c := testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret-token") })
+	if c.Response.Status == http.StatusUnauthorized {
+		t.Fatal("Should have authenticated bearer token")
+	}
+
+	c = testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.Header.Set("Authorization", "Bearer other") })
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatal("Should have rejected unknown bearer token")
+	}
+}
+
+func TestHTTPAuthSkip(t *testing.T) {
+	httpAuth := newHTTPAuth(t)
+	httpAuth.Skip("Public.*")
+
+	c := testHTTPAuth(t, httpAuth, "Public.Index", func(r *http.Request) {})
+	if c.Response.Status == http.StatusUnauthorized {
+		t.Fatal("Should have skipped authentication")
+	}
+}
+
+func TestHTTPAuthSecondFactor(t *testing.T) {
+	httpAuth := newHTTPAuth(t)
+	httpAuth.NewUser = func(userId, secret string) auth.UserAuth {
+		u := NewUser(userId, secret)
+		u.totp = "JBSWY3DPEHPK3PXP"
+		return u
+	}
+
+	c := testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.SetBasicAuth("demo@domain.com", "demopass") })
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatalf("Should have refused the password alone of a user with a second factor, got %d", c.Response.Status)
+	}
+}
+
+func TestHTTPAuthUnknownUser(t *testing.T) {
+	httpAuth := newHTTPAuth(t)
+
+	c := testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.SetBasicAuth("nobody@domain.com", "demopass") })
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatalf("Should have rejected unknown user, got %d", c.Response.Status)
+	}
+
+	// unknown users are throttled like existing ones
+	c = testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.SetBasicAuth("nobody@domain.com", "demopass") })
+	if c.Response.Status != http.StatusTooManyRequests {
+		t.Fatalf("Should have throttled the retry, got %d", c.Response.Status)
+	}
+}
+
+func TestHTTPAuthThrottle(t *testing.T) {
+	httpAuth := newHTTPAuth(t)
+
+	c := testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.SetBasicAuth("demo@domain.com", "invalid") })
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatal("Should have rejected invalid password")
+	}
+
+	// retrying right away is refused, even with the right password
+	c = testHTTPAuth(t, httpAuth, "App.Index", func(r *http.Request) { r.SetBasicAuth("demo@domain.com", "demopass") })
+	if c.Response.Status != http.StatusTooManyRequests {
+		t.Fatalf("Should have throttled the retry, got %d", c.Response.Status)
+	}
+	if header := c.Response.Out.Header().Get("Retry-After"); header != "1" {
+		t.Fatalf("Should have sent Retry-After, got %q", header)
+	}
+}
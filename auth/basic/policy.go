@@ -0,0 +1,189 @@
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/revel/revel"
+)
+
+// Password policy rules, as found in PolicyError.Rule.
+const (
+	RuleMinLength = "min_length"
+	RuleMaxLength = "max_length"
+	RuleLower     = "lower"
+	RuleUpper     = "upper"
+	RuleDigit     = "digit"
+	RuleSymbol    = "symbol"
+	RuleBanned    = "banned"
+	RuleUsername  = "username"
+)
+
+// PolicyError is a rule of the password policy a password does not follow.
+type PolicyError struct {
+	Rule    string
+	Message string
+}
+
+func (e *PolicyError) Error() string {
+	return e.Message
+}
+
+// PolicyErrors lists every rule a password does not follow.
+type PolicyErrors []*PolicyError
+
+func (e PolicyErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Message
+	}
+	return "password policy: " + strings.Join(messages, " ")
+}
+
+// PasswordPolicy holds the rules new passwords must follow.
+type PasswordPolicy struct {
+	MinLength int // in characters
+	MaxLength int // in bytes, 0 for no limit
+	Lower     bool
+	Upper     bool
+	Digit     bool
+	Symbol    bool
+	// Banned holds refused passwords in lower case.
+	Banned map[string]bool
+	// NotUsername refuses passwords equal to the user id, ignoring case.
+	NotUsername bool
+}
+
+// Policy is checked by the secret drivers before hashing a new password, nil disables the checks.
+var Policy = &PasswordPolicy{
+	MinLength:   8,
+	MaxLength:   72,
+	NotUsername: true,
+}
+
+func init() {
+	revel.OnAppStart(loadPolicyConfig)
+}
+
+func loadPolicyConfig() {
+	Policy = &PasswordPolicy{
+		MinLength:   revel.Config.IntDefault("auth.password.minlength", 8),
+		MaxLength:   revel.Config.IntDefault("auth.password.maxlength", 72),
+		Lower:       revel.Config.BoolDefault("auth.password.lower", false),
+		Upper:       revel.Config.BoolDefault("auth.password.upper", false),
+		Digit:       revel.Config.BoolDefault("auth.password.digit", false),
+		Symbol:      revel.Config.BoolDefault("auth.password.symbol", false),
+		NotUsername: revel.Config.BoolDefault("auth.password.username", true),
+	}
+
+	if path := revel.Config.StringDefault("auth.password.banned", ""); path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(revel.BasePath, path)
+		}
+		if err := Policy.LoadBanned(path); err != nil {
+			panic(fmt.Sprintf("auth: invalid auth.password.banned: %v", err))
+		}
+	}
+}
+
+// LoadBanned adds the passwords listed in a file, one per line, to the banned passwords.
+// Empty lines and lines starting with # are ignored.
+func (p *PasswordPolicy) LoadBanned(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if p.Banned == nil {
+		p.Banned = map[string]bool{}
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			p.Banned[strings.ToLower(line)] = true
+		}
+	}
+	return scanner.Err()
+}
+
+// Check returns PolicyErrors listing the rules the password does not follow, or nil.
+func (p *PasswordPolicy) Check(password, userId string) error {
+	var errs PolicyErrors
+	fail := func(rule, format string, args ...interface{}) {
+		errs = append(errs, &PolicyError{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		fail(RuleMinLength, "Password must be at least %d characters long.", p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		fail(RuleMaxLength, "Password must be at most %d bytes long.", p.MaxLength)
+	}
+
+	var lower, upper, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r):
+			symbol = true
+		}
+	}
+	if p.Lower && !lower {
+		fail(RuleLower, "Password must contain a lower case letter.")
+	}
+	if p.Upper && !upper {
+		fail(RuleUpper, "Password must contain an upper case letter.")
+	}
+	if p.Digit && !digit {
+		fail(RuleDigit, "Password must contain a digit.")
+	}
+	if p.Symbol && !symbol {
+		fail(RuleSymbol, "Password must contain a symbol.")
+	}
+
+	if p.Banned[strings.ToLower(password)] {
+		fail(RuleBanned, "Password is too common.")
+	}
+	if p.NotUsername && userId != "" && strings.EqualFold(password, userId) {
+		fail(RuleUsername, "Password must differ from the username.")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// CheckPassword checks a new password against the Policy.
+// It returns PolicyErrors listing the rules the password does not follow, or nil.
+func CheckPassword(password, userId string) error {
+	if Policy == nil {
+		return nil
+	}
+	return Policy.Check(password, userId)
+}
+
+// ValidatePassword checks a new password against the Policy and adds an error to the
+// validation for each rule it does not follow, under key. It returns true when the password is valid.
+//
+//	auth.ValidatePassword(c.Validation, "password", password, username)
+//	if c.Validation.HasErrors() { ... }
+func ValidatePassword(v *revel.Validation, key, password, userId string) bool {
+	errs, _ := CheckPassword(password, userId).(PolicyErrors)
+	for _, err := range errs {
+		v.Error(err.Message).Key(key)
+	}
+	return len(errs) == 0
+}
@@ -0,0 +1,78 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+)
+
+func policyRules(err error) (rules []string) {
+	errs, _ := err.(auth.PolicyErrors)
+	for _, e := range errs {
+		rules = append(rules, e.Rule)
+	}
+	return
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	banned := filepath.Join(t.TempDir(), "banned.txt")
+	if err := os.WriteFile(banned, []byte("# common passwords\nPassword1!\n\nletmein123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	policy := &auth.PasswordPolicy{MinLength: 10, MaxLength: 72, Lower: true, Upper: true, Digit: true, Symbol: true, NotUsername: true}
+	if err := policy.LoadBanned(banned); err != nil {
+		t.Fatal(err)
+	}
+
+	for password, expected := range map[string]string{
+		"Correct-Horse-9":          "",
+		"short":                    "min_length upper digit symbol",
+		strings.Repeat("aA1-", 20): "max_length",
+		"password9!":               "upper",
+		"PASSWORD9!":               "lower",
+		"Password!!":               "digit",
+		"Password11":               "symbol",
+		"password1!A":              "",
+		"PassWord1!":               "banned",
+		"Demo@Domain.com1":         "",
+		"Demo@Domain.com":          "digit username",
+	} {
+		if rules := strings.Join(policyRules(policy.Check(password, "demo@domain.com")), " "); rules != expected {
+			t.Errorf("%q should break %q, got %q", password, expected, rules)
+		}
+	}
+}
+
+func TestPolicyInHashSecret(t *testing.T) {
+	auth.Store = &TestStore{data: make(map[string]string)}
+
+	u := NewUser("demo@domain.com", "demo@domain.com")
+	_, err := u.HashSecret(u.Secret())
+	if rules := policyRules(err); len(rules) != 1 || rules[0] != auth.RuleUsername {
+		t.Fatalf("Should have refused the username as password, got %v", err)
+	}
+	if u.HashedSecret() != "" {
+		t.Fatal("Should not have hashed the refused password")
+	}
+
+	// bcrypt ignores anything after 72 bytes, whatever the policy
+	defer func(policy *auth.PasswordPolicy) { auth.Policy = policy }(auth.Policy)
+	auth.Policy = nil
+	if _, err := u.HashSecret(strings.Repeat("a", 73)); err == nil {
+		t.Fatal("Should have refused a password longer than 72 bytes")
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	v := &revel.Validation{Request: &revel.Request{}}
+	if auth.ValidatePassword(v, "password", "short", "demo@domain.com") {
+		t.Fatal("Should have refused the short password")
+	}
+	if err := v.ErrorMap()["password"]; err == nil || err.Message != "Password must be at least 8 characters long." {
+		t.Fatalf("Should have added the error under the key, got %v", v.Errors)
+	}
+}
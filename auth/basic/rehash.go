@@ -0,0 +1,45 @@
+package auth
+
+import "github.com/revel/revel"
+
+// Rehasher is implemented by secret drivers able to tell that the stored hash
+// was created with an outdated algorithm or cost, and should be replaced.
+type Rehasher interface {
+	NeedsRehash() bool
+	// Rehash hashes the plain text Secret() again with the current settings and stores it with SetHashedSecret.
+	// Unlike HashSecret it does not check the password Policy, so passwords set under an older policy are upgraded too.
+	Rehash() error
+}
+
+// Authenticate checks the secret of a user loaded from the Store with its SecretDriver.
+// When the secret matches an outdated hash, it is hashed again with Rehash
+// and the user is saved through the Store, so hashes are upgraded as users log in.
+// Failing to upgrade the hash is logged and does not fail the authentication.
+func Authenticate(user UserAuth) (bool, error) {
+	ok, err := user.Authenticate()
+	if !ok || err != nil {
+		return ok, err
+	}
+
+	if rehasher, isRehasher := user.(Rehasher); isRehasher && rehasher.NeedsRehash() {
+		if err := rehash(user, rehasher); err != nil {
+			revel.AppLog.Warn("Failed to rehash secret", "user", user.UserId(), "error", err)
+		}
+	}
+
+	return true, nil
+}
+
+func rehash(user UserAuth, rehasher Rehasher) error {
+	previous := user.HashedSecret()
+	if err := rehasher.Rehash(); err != nil {
+		user.SetHashedSecret(previous)
+		return err
+	}
+	if err := Store.Save(user); err != nil {
+		// keep the user consistent with the Store
+		user.SetHashedSecret(previous)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+// SessionUserKey is the session key holding the id of the logged in user.
+const SessionUserKey = "auth_user"
+
+// NewUser returns the app-level user model for a user id and a plain text secret.
+// It must be set to use the session login, e.g.
+//
+//	auth.NewUser = func(userId, secret string) auth.UserAuth { return models.NewUser(userId, secret) }
+var NewUser func(userId, secret string) UserAuth
+
+// OnSessionRenew is called when LogIn and LogOut renew the session, e.g. to issue a new CSRF token:
+//
+//	auth.OnSessionRenew = func(c *revel.Controller) { csrf.Rotate(c) }
+var OnSessionRenew func(c *revel.Controller)
+
+var (
+	loginURL       = "/login"
+	loginRedirect  = "/"
+	logoutRedirect = "/"
+
+	public ActionSet
+)
+
+func init() {
+	revel.OnAppStart(func() {
+		loginURL = revel.Config.StringDefault("auth.login.url", loginURL)
+		loginRedirect = revel.Config.StringDefault("auth.login.redirect", loginRedirect)
+		logoutRedirect = revel.Config.StringDefault("auth.logout.redirect", logoutRedirect)
+	})
+	revel.InterceptMethod((*Authenticated).requireLogin, revel.BEFORE)
+}
+
+// Authenticated is embedded into controllers whose actions all require a logged in user,
+// instead of *revel.Controller:
+//
+//	type Admin struct {
+//		auth.Authenticated
+//	}
+type Authenticated struct {
+	*revel.Controller
+}
+
+// CurrentUser returns the logged in user.
+func (c Authenticated) CurrentUser() UserAuth {
+	return CurrentUser(c.Controller)
+}
+
+func (c *Authenticated) requireLogin() revel.Result {
+	if SessionUser(c.Controller) == "" {
+		return loginRequired(c.Controller)
+	}
+	return nil
+}
+
+// LogIn starts a session for an authenticated user.
+// The previous session content is dropped and a new session id is used (see OnSessionRenew),
+// so a session planted by an attacker before the login is never authenticated.
+func LogIn(c *revel.Controller, user UserAuth) {
+	renewSession(c)
+	c.Session[SessionUserKey] = user.UserId()
+	c.Args[UserArgKey] = user
+}
+
+// LogOut ends the session of the logged in user, with a new session id.
+func LogOut(c *revel.Controller) {
+	renewSession(c)
+	delete(c.Args, UserArgKey)
+}
+
+// SessionUser returns the id of the user logged in the session, or an empty string.
+func SessionUser(c *revel.Controller) string {
+	userId, _ := c.Session[SessionUserKey].(string)
+	return userId
+}
+
+// Public lets visitors reach actions behind RequireLogin, in the form of "ControllerName.ActionName"
+// or "ControllerName.*". The login page must be public.
+func Public(actions ...string) {
+	for _, action := range actions {
+		public.Add(action)
+	}
+}
+
+// RequireLogin sends visitors without a logged in user to the `auth.login.url` page,
+// with the requested URL in the `return_to` parameter. JSON requests are answered with 401 Unauthorized.
+// It must come after the revel.SessionFilter and the revel.RouterFilter.
+func RequireLogin(c *revel.Controller, fc []revel.Filter) {
+	if SessionUser(c) == "" && !public.Contains(c.Action) && c.Request.GetPath() != loginURL {
+		c.Result = loginRequired(c)
+		return
+	}
+	fc[0](c, fc[1:])
+}
+
+func loginRequired(c *revel.Controller) revel.Result {
+	if c.Request.Format == "json" {
+		c.Response.Status = http.StatusUnauthorized
+		return c.RenderError(errors.New("401: Login required"))
+	}
+
+	target := loginURL
+	if c.Request.Method == "GET" {
+		target += "?return_to=" + url.QueryEscape(c.Request.GetRequestURI())
+	}
+	return c.Redirect(target)
+}
+
+// ReturnTo returns the URL to go to after logging in: returnTo when it is a path of this app,
+// the `auth.login.redirect` setting otherwise.
+func ReturnTo(returnTo string) string {
+	if !localPath(returnTo) {
+		return loginRedirect
+	}
+	return returnTo
+}
+
+// localPath returns true when target is an absolute path without a host.
+// Browsers drop tabs and newlines from URLs and read backslashes as slashes,
+// so "/\t/evil.com" or "/\evil.com" would leave the app: both are refused.
+func localPath(target string) bool {
+	if strings.ContainsRune(target, '\\') || strings.IndexFunc(target, unicode.IsControl) != -1 {
+		return false
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.User != nil {
+		return false
+	}
+	return strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(u.Path, "//")
+}
+
+// LoginURL returns the `auth.login.url` setting.
+func LoginURL() string {
+	return loginURL
+}
+
+// LogoutRedirect returns the `auth.logout.redirect` setting.
+func LogoutRedirect() string {
+	return logoutRedirect
+}
+
+// renewSession drops the session content and id, keeping its expiration setting, and calls OnSessionRenew.
+func renewSession(c *revel.Controller) {
+	for key := range c.Session {
+		if key != session.TimestampKey {
+			delete(c.Session, key)
+		}
+	}
+	if OnSessionRenew != nil {
+		OnSessionRenew(c)
+	}
+}
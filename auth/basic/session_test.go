@@ -0,0 +1,118 @@
+package auth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+	"github.com/revel/revel/logger"
+	"github.com/revel/revel/session"
+)
+
+func testRequireLogin(t *testing.T, method, target, accept string, setup func(c *revel.Controller)) *revel.Controller {
+	t.Helper()
+	r, _ := http.NewRequest(method, target, nil)
+	r.Header.Set("Accept", accept)
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(r)
+	context.Response.SetResponse(httptest.NewRecorder())
+	c := revel.NewController(context)
+	c.Log = logger.New("module", "test")
+	c.Session = make(session.Session)
+	c.Action = "Admin.Index"
+	c.Request.Format = revel.ResolveFormat(c.Request)
+	setup(c)
+
+	filters := []revel.Filter{
+		auth.RequireLogin,
+		func(c *revel.Controller, fc []revel.Filter) {
+			c.Result = c.RenderText("OK.")
+		},
+	}
+	filters[0](c, filters[1:])
+	return c
+}
+
+func TestLogIn(t *testing.T) {
+	auth.Store = &TestStore{data: make(map[string]string)}
+	auth.NewUser = func(userId, secret string) auth.UserAuth { return NewUser(userId, secret) }
+	defer func() { auth.NewUser = nil }()
+	renewed := 0
+	auth.OnSessionRenew = func(*revel.Controller) { renewed++ }
+	defer func() { auth.OnSessionRenew = nil }()
+	if err := auth.Store.Save(NewUser("demo@domain.com", "demopass")); err != nil {
+		t.Fatal(err)
+	}
+
+	c := testRequireLogin(t, "GET", "http://www.example.com/", "text/html", func(c *revel.Controller) {})
+	c.Session["planted"] = "by an attacker"
+	id := c.Session.ID()
+
+	auth.LogIn(c, NewUser("demo@domain.com", "demopass"))
+	if c.Session["planted"] != nil || c.Session.ID() == id {
+		t.Fatal("Should have renewed the session")
+	}
+	if renewed != 1 {
+		t.Fatalf("Should have called OnSessionRenew once, got %d", renewed)
+	}
+	if auth.SessionUser(c) != "demo@domain.com" {
+		t.Fatalf("Should have stored the user in the session, got %q", auth.SessionUser(c))
+	}
+
+	// a later request loads the user from the session
+	delete(c.Args, auth.UserArgKey)
+	if user := auth.CurrentUser(c); user == nil || user.UserId() != "demo@domain.com" || user.HashedSecret() == "" {
+		t.Fatalf("Should have loaded the session user, got %v", user)
+	}
+
+	auth.LogOut(c)
+	if auth.SessionUser(c) != "" || auth.CurrentUser(c) != nil || renewed != 2 {
+		t.Fatal("Should have logged the user out")
+	}
+}
+
+func TestRequireLogin(t *testing.T) {
+	c := testRequireLogin(t, "GET", "http://www.example.com/admin?page=2", "text/html", func(c *revel.Controller) {})
+	if _, ok := c.Result.(*revel.RedirectToURLResult); !ok {
+		t.Fatalf("Should have redirected to the login page, got %#v", c.Result)
+	}
+
+	c = testRequireLogin(t, "GET", "http://www.example.com/admin", "application/json", func(c *revel.Controller) {})
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatalf("Should have refused JSON requests, got %d", c.Response.Status)
+	}
+
+	c = testRequireLogin(t, "GET", "http://www.example.com/admin", "text/html", func(c *revel.Controller) {
+		c.Session[auth.SessionUserKey] = "demo@domain.com"
+	})
+	if _, ok := c.Result.(*revel.RenderTextResult); !ok {
+		t.Fatalf("Should have let the logged in user through, got %#v", c.Result)
+	}
+
+	auth.Public("Admin.*")
+	c = testRequireLogin(t, "GET", "http://www.example.com/admin", "text/html", func(c *revel.Controller) {})
+	if _, ok := c.Result.(*revel.RenderTextResult); !ok {
+		t.Fatalf("Should have let visitors reach public actions, got %#v", c.Result)
+	}
+}
+
+func TestReturnTo(t *testing.T) {
+	for returnTo, expected := range map[string]string{
+		"/admin?page=2":       "/admin?page=2",
+		"":                    "/",
+		"http://evil.com/":    "/",
+		"//evil.com/":         "/",
+		"/\\evil.com/":        "/",
+		"/\t/evil.com/":       "/",
+		"/\n/evil.com/":       "/",
+		"/admin\\..\\x":       "/",
+		"/%2F/evil.com/":      "/",
+		"javascript:alert(1)": "/",
+	} {
+		if target := auth.ReturnTo(returnTo); target != expected {
+			t.Errorf("ReturnTo(%q) should be %q, got %q", returnTo, expected, target)
+		}
+	}
+}
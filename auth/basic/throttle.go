@@ -0,0 +1,440 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// AttemptTracker counts failed logins per key, e.g. per user or per client address.
+// The attempts in progress are counted with it too, under keys of their own.
+// Set Tracker to the implementation shared by the app servers, or nil to disable throttling.
+type AttemptTracker interface {
+	// Failures returns the number of failures recorded for the key and the time of the last one.
+	Failures(key string) (count int, last time.Time, err error)
+	// AddFailure records a failure for the key and returns the updated count.
+	// The count starts over when the last failure is older than window.
+	AddFailure(key string, at time.Time, window time.Duration) (count int, err error)
+	// RemoveFailure takes back one failure of the key, leaving the time of the last one.
+	RemoveFailure(key string) error
+	// Reset forgets the failures of the key.
+	Reset(key string) error
+}
+
+// Tracker records the failed logins used by Login, in memory by default.
+var Tracker AttemptTracker = NewMemoryTracker()
+
+var (
+	lockoutFailures   = 5
+	lockoutDuration   = 15 * time.Minute
+	ipLockoutFailures = 20
+	throttleDelay     = time.Second
+	throttleMaxDelay  = time.Minute
+	throttleWindow    = time.Hour
+)
+
+// ThrottledError is returned by Login when the user or the client address must wait before trying again.
+type ThrottledError struct {
+	RetryAfter time.Duration
+	// Locked is set when the attempt was refused after too many failures, rather than delayed.
+	Locked bool
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins, locked out for %v", e.RetryAfter)
+	}
+	return fmt.Sprintf("login attempted too soon, retry in %v", e.RetryAfter)
+}
+
+// ErrInvalidSecret is returned by Login when the secret does not match.
+var ErrInvalidSecret = errors.New("auth: invalid secret")
+
+func init() {
+	revel.OnAppStart(loadThrottleConfig)
+}
+
+func loadThrottleConfig() {
+	lockoutFailures = revel.Config.IntDefault("auth.lockout.failures", lockoutFailures)
+	ipLockoutFailures = revel.Config.IntDefault("auth.throttle.ip.failures", ipLockoutFailures)
+	lockoutDuration = DurationConfig("auth.lockout.duration", lockoutDuration)
+	throttleDelay = DurationConfig("auth.throttle.delay", throttleDelay)
+	throttleMaxDelay = DurationConfig("auth.throttle.maxdelay", throttleMaxDelay)
+	throttleWindow = DurationConfig("auth.throttle.window", throttleWindow)
+}
+
+// Login authenticates a user loaded from the Store like Authenticate,
+// refusing the attempt while the user or the client address is throttled.
+// The attempt is started with StartAttempt for both before the secret is checked, so parallel
+// attempts cannot get past the lockout. A failure is recorded for both, a success clears the failures of the user.
+// It returns a *ThrottledError when the attempt was refused, and ErrInvalidSecret when the secret does not match.
+func Login(user UserAuth, clientIP string) (bool, error) {
+	if err := CheckThrottle(user.UserId(), clientIP); err != nil {
+		return false, err
+	}
+
+	keys := throttleKeys(user.UserId(), clientIP)
+	if err := startAttempts(user.UserId(), keys); err != nil {
+		return false, err
+	}
+
+	ok, err := Authenticate(user)
+	if err != nil {
+		endAttempts(keys, false)
+		return false, err
+	}
+	if err := endAttempts(keys, !ok); err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, ErrInvalidSecret
+	}
+
+	if Tracker == nil {
+		return true, nil
+	}
+	return true, Tracker.Reset(userKey(user.UserId()))
+}
+
+// LoginUnknown answers the login of a user the Store could not load like a wrong secret, so the response
+// does not tell whether the user exists: the attempt is throttled and recorded as a failure like with Login,
+// and the secret is checked against a dummy hash with the SecretDriver of the user.
+// It returns a *ThrottledError when the attempt was refused, and ErrInvalidSecret otherwise.
+func LoginUnknown(user UserAuth, clientIP string) error {
+	if err := CheckThrottle(user.UserId(), clientIP); err != nil {
+		return err
+	}
+	keys := throttleKeys(user.UserId(), clientIP)
+	if err := startAttempts(user.UserId(), keys); err != nil {
+		return err
+	}
+
+	if hash := dummyHash(user); hash != "" {
+		user.SetHashedSecret(hash)
+		_, _ = user.Authenticate()
+	}
+	if err := endAttempts(keys, true); err != nil {
+		return err
+	}
+	return ErrInvalidSecret
+}
+
+var (
+	dummyLock   sync.Mutex
+	dummyHashes = map[reflect.Type]string{}
+)
+
+// dummyHash returns a hash of a random password made by the SecretDriver of the user model,
+// so checking it takes as long as checking the hash of an existing user.
+func dummyHash(user UserAuth) string {
+	dummyLock.Lock()
+	defer dummyLock.Unlock()
+
+	userType := reflect.TypeOf(user)
+	if hash, found := dummyHashes[userType]; found {
+		return hash
+	}
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return ""
+	}
+	// mixed characters, for the password policy
+	hash, err := user.HashSecret("Aa1!" + hex.EncodeToString(random))
+	if err != nil {
+		revel.AppLog.Warn("Failed to create the dummy hash of unknown users", "error", err)
+	}
+	dummyHashes[userType] = hash
+	return hash
+}
+
+const (
+	// pendingWindow forgets the attempts in progress of a server stopped while checking their secret.
+	pendingWindow = time.Minute
+	// attemptWait bounds the wait of an attempt for the attempts in progress before it.
+	attemptWait = 10 * time.Second
+	attemptPoll = 20 * time.Millisecond
+)
+
+// StartAttempt records an attempt on the key as in progress, before its secret is checked, so that parallel
+// attempts cannot get past maxFailures (0 for no limit): no more attempts than the failures left, or a single one
+// once a lockout has ended, are checked at the same time, the others wait for them to end.
+// Attempts in progress are counted apart from the failures, so they never count against a valid attempt.
+// Failures older than window are forgotten, and maxFailures of them lock the key out for lockout.
+// It returns a *ThrottledError when the attempt is refused,
+// otherwise EndAttempt must be called once the secret is checked.
+func StartAttempt(key string, maxFailures int, window, lockout time.Duration) error {
+	if Tracker == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(attemptWait)
+	for {
+		started, err := tryAttempt(key, maxFailures, window, lockout)
+		if started || err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return &ThrottledError{RetryAfter: throttleDelay}
+		}
+		time.Sleep(attemptPoll)
+	}
+}
+
+// tryAttempt starts the attempt of StartAttempt, unless too many attempts on the key are in progress.
+func tryAttempt(key string, maxFailures int, window, lockout time.Duration) (bool, error) {
+	now := time.Now()
+	pending, err := Tracker.AddFailure(pendingKey(key), now, pendingWindow)
+	if err != nil {
+		return false, err
+	}
+	// read the failures after counting the attempt, an attempt ending meanwhile is seen in either count
+	count, last, err := Tracker.Failures(key)
+	if err != nil {
+		Tracker.RemoveFailure(pendingKey(key))
+		return false, err
+	}
+	elapsed := now.Sub(last)
+	if elapsed > window {
+		count = 0
+	}
+	if maxFailures <= 0 {
+		return true, nil
+	}
+
+	allowed := maxFailures - count
+	if count >= maxFailures {
+		if elapsed < lockout {
+			Tracker.RemoveFailure(pendingKey(key))
+			return false, &ThrottledError{RetryAfter: lockout - elapsed, Locked: true}
+		}
+		allowed = 1
+	}
+	if pending > allowed {
+		return false, Tracker.RemoveFailure(pendingKey(key))
+	}
+	return true, nil
+}
+
+// EndAttempt ends an attempt started with StartAttempt, recording a failure of the key when failed is set.
+func EndAttempt(key string, failed bool, window time.Duration) error {
+	if Tracker == nil {
+		return nil
+	}
+
+	var err error
+	if failed {
+		// before ending the attempt, so that it is always counted
+		_, err = Tracker.AddFailure(key, time.Now(), window)
+	}
+	if removeErr := Tracker.RemoveFailure(pendingKey(key)); err == nil {
+		err = removeErr
+	}
+	return err
+}
+
+// startAttempts starts the attempt of the user on the keys of Login.
+func startAttempts(userId string, keys []string) error {
+	for i, key := range keys {
+		if err := StartAttempt(key, maxFailures(userId, key), window(), lockoutDuration); err != nil {
+			endAttempts(keys[:i], false)
+			return err
+		}
+	}
+	return nil
+}
+
+// endAttempts ends the attempt on the keys of Login.
+func endAttempts(keys []string, failed bool) error {
+	var err error
+	for _, key := range keys {
+		if endErr := EndAttempt(key, failed, window()); endErr != nil {
+			err = endErr
+		}
+	}
+	return err
+}
+
+// CheckThrottle returns a *ThrottledError when the user or the client address may not attempt to log in yet.
+func CheckThrottle(userId, clientIP string) error {
+	if Tracker == nil {
+		return nil
+	}
+
+	now := time.Now()
+	var throttled *ThrottledError
+	for _, key := range throttleKeys(userId, clientIP) {
+		count, last, err := Tracker.Failures(key)
+		if err != nil {
+			return err
+		}
+		if count == 0 || now.Sub(last) > window() {
+			continue
+		}
+
+		wait := retryAfter(count, maxFailures(userId, key), now.Sub(last))
+		if wait != nil && (throttled == nil || wait.RetryAfter > throttled.RetryAfter) {
+			throttled = wait
+		}
+	}
+
+	if throttled != nil {
+		return throttled
+	}
+	return nil
+}
+
+// Unlock clears the failed logins of a user, lifting a lockout.
+func Unlock(userId string) error {
+	if Tracker == nil {
+		return nil
+	}
+	return Tracker.Reset(userKey(userId))
+}
+
+// UnlockAddress clears the failed logins of a client address, lifting a lockout.
+func UnlockAddress(clientIP string) error {
+	if Tracker == nil {
+		return nil
+	}
+	return Tracker.Reset(addressKey(clientIP))
+}
+
+// maxFailures returns the failures allowed for the key of the user or of the client address.
+func maxFailures(userId, key string) int {
+	if key != userKey(userId) {
+		return ipLockoutFailures
+	}
+	return lockoutFailures
+}
+
+// retryAfter returns the wait left after count failures, the last one elapsed ago.
+func retryAfter(count, maxFailures int, elapsed time.Duration) *ThrottledError {
+	if maxFailures > 0 && count >= maxFailures {
+		if elapsed < lockoutDuration {
+			return &ThrottledError{RetryAfter: lockoutDuration - elapsed, Locked: true}
+		}
+		return nil
+	}
+
+	delay := throttleMaxDelay
+	if shift := uint(count - 1); shift < 32 && throttleDelay<<shift < throttleMaxDelay {
+		delay = throttleDelay << shift
+	}
+	if elapsed < delay {
+		return &ThrottledError{RetryAfter: delay - elapsed}
+	}
+	return nil
+}
+
+// window is the time failures are remembered, never shorter than a lockout.
+func window() time.Duration {
+	if throttleWindow < lockoutDuration {
+		return lockoutDuration
+	}
+	return throttleWindow
+}
+
+func throttleKeys(userId, clientIP string) []string {
+	keys := []string{userKey(userId)}
+	if clientIP != "" {
+		keys = append(keys, addressKey(clientIP))
+	}
+	return keys
+}
+
+func userKey(userId string) string {
+	return "user:" + userId
+}
+
+func addressKey(clientIP string) string {
+	return "ip:" + clientIP
+}
+
+func pendingKey(key string) string {
+	return "pending:" + key
+}
+
+// MemoryTracker is an AttemptTracker keeping the failures in memory.
+// It only suits apps running on a single server.
+type MemoryTracker struct {
+	lock     sync.Mutex
+	attempts map[string]*attempts
+	added    int
+}
+
+type attempts struct {
+	count   int
+	last    time.Time
+	expires time.Time
+}
+
+// NewMemoryTracker returns an empty MemoryTracker.
+func NewMemoryTracker() *MemoryTracker {
+	return &MemoryTracker{attempts: map[string]*attempts{}}
+}
+
+// Failures returns the number of failures recorded for the key and the time of the last one.
+func (t *MemoryTracker) Failures(key string) (int, time.Time, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	a, found := t.attempts[key]
+	if !found {
+		return 0, time.Time{}, nil
+	}
+	return a.count, a.last, nil
+}
+
+// AddFailure records a failure for the key and returns the updated count.
+func (t *MemoryTracker) AddFailure(key string, at time.Time, window time.Duration) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	// drop expired entries from time to time, so addresses seen once do not pile up
+	if t.added++; t.added%1000 == 0 {
+		for k, a := range t.attempts {
+			if at.After(a.expires) {
+				delete(t.attempts, k)
+			}
+		}
+	}
+
+	a, found := t.attempts[key]
+	if !found || at.After(a.expires) {
+		a = &attempts{}
+		t.attempts[key] = a
+	}
+	a.count++
+	a.last = at
+	a.expires = at.Add(window)
+	return a.count, nil
+}
+
+// RemoveFailure takes back one failure of the key.
+func (t *MemoryTracker) RemoveFailure(key string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if a, found := t.attempts[key]; found {
+		if a.count--; a.count <= 0 {
+			delete(t.attempts, key)
+		}
+	}
+	return nil
+}
+
+// Reset forgets the failures of the key.
+func (t *MemoryTracker) Reset(key string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.attempts, key)
+	return nil
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type throttleUser struct {
+	secret string
+}
+
+func (u *throttleUser) UserId() string                                 { return "demo@domain.com" }
+func (u *throttleUser) Secret() string                                 { return u.secret }
+func (u *throttleUser) HashedSecret() string                           { return "demopass" }
+func (u *throttleUser) SetHashedSecret(string)                         {}
+func (u *throttleUser) Authenticate() (bool, error)                    { return u.secret == "demopass", nil }
+func (u *throttleUser) HashSecret(args ...interface{}) (string, error) { return "demopass", nil }
+
+func TestRetryAfter(t *testing.T) {
+	for _, test := range []struct {
+		count   int
+		elapsed time.Duration
+		wait    time.Duration
+		locked  bool
+	}{
+		{1, 0, time.Second, false},
+		{2, 0, 2 * time.Second, false},
+		{3, time.Second, 3 * time.Second, false},
+		{4, 8 * time.Second, 0, false},
+		{5, time.Minute, 14 * time.Minute, true},
+		{5, 15 * time.Minute, 0, false},
+	} {
+		wait := retryAfter(test.count, lockoutFailures, test.elapsed)
+		switch {
+		case test.wait == 0 && wait != nil:
+			t.Errorf("%d failures %v ago should not wait, got %v", test.count, test.elapsed, wait)
+		case test.wait != 0 && (wait == nil || wait.RetryAfter != test.wait || wait.Locked != test.locked):
+			t.Errorf("%d failures %v ago should wait %v (locked %v), got %#v", test.count, test.elapsed, test.wait, test.locked, wait)
+		}
+	}
+
+	// the delay is capped
+	if wait := retryAfter(40, 0, 0); wait == nil || wait.RetryAfter != throttleMaxDelay {
+		t.Errorf("Should wait at most %v, got %#v", throttleMaxDelay, wait)
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	Tracker = NewMemoryTracker()
+	defer func(delay time.Duration) { throttleDelay = delay }(throttleDelay)
+	throttleDelay = 0
+
+	for i := 0; i < lockoutFailures; i++ {
+		if ok, err := Login(&throttleUser{"invalid"}, "10.0.0.1"); ok || err != ErrInvalidSecret {
+			t.Fatalf("Should have failed to authenticate, got %v %v", ok, err)
+		}
+	}
+
+	// the right secret is refused while locked out, from any address
+	_, err := Login(&throttleUser{"demopass"}, "10.0.0.2")
+	if throttled, ok := err.(*ThrottledError); !ok || !throttled.Locked {
+		t.Fatalf("Should have locked the user out, got %v", err)
+	}
+
+	if err := Unlock("demo@domain.com"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Login(&throttleUser{"demopass"}, "10.0.0.1"); !ok || err != nil {
+		t.Fatalf("Should have authenticated the unlocked user, got %v", err)
+	}
+	if count, _, _ := Tracker.Failures(userKey("demo@domain.com")); count != 0 {
+		t.Errorf("Should have cleared the failures on success, got %d", count)
+	}
+}
+
+func TestLoginAfterLockout(t *testing.T) {
+	Tracker = NewMemoryTracker()
+
+	// the lockout has ended, but the failures are still within the window
+	for i := 0; i < lockoutFailures; i++ {
+		Tracker.AddFailure(userKey("demo@domain.com"), time.Now().Add(-lockoutDuration-time.Second), window())
+	}
+	if ok, err := Login(&throttleUser{"demopass"}, "10.0.0.1"); !ok || err != nil {
+		t.Fatalf("Should have authenticated the user after the lockout, got %v", err)
+	}
+}
+
+func TestParallelValidLogins(t *testing.T) {
+	Tracker = NewMemoryTracker()
+
+	var checks, failed int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4*lockoutFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, err := Login(&slowUser{throttleUser{"demopass"}, &checks}, "10.0.0.1"); !ok || err != nil {
+				atomic.AddInt32(&failed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if failed != 0 {
+		t.Errorf("Should have authenticated every parallel login, %d failed", failed)
+	}
+}
+
+type slowUser struct {
+	throttleUser
+	checks *int32
+}
+
+func (u *slowUser) Authenticate() (bool, error) {
+	atomic.AddInt32(u.checks, 1)
+	time.Sleep(10 * time.Millisecond)
+	return u.throttleUser.Authenticate()
+}
+
+func TestParallelLoginLockout(t *testing.T) {
+	Tracker = NewMemoryTracker()
+	defer func(delay time.Duration) { throttleDelay = delay }(throttleDelay)
+	throttleDelay = 0
+
+	var checks int32
+	var wg sync.WaitGroup
+	for i := 0; i < 4*lockoutFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			Login(&slowUser{throttleUser{"invalid"}, &checks}, "10.0.0.1")
+		}()
+	}
+	wg.Wait()
+
+	if checks > int32(lockoutFailures) {
+		t.Errorf("Should have checked at most %d secrets, checked %d", lockoutFailures, checks)
+	}
+	if count, _, _ := Tracker.Failures(userKey("demo@domain.com")); count != lockoutFailures {
+		t.Errorf("Should have recorded %d failures, got %d", lockoutFailures, count)
+	}
+}
+
+func TestLoginReleasesAddress(t *testing.T) {
+	Tracker = NewMemoryTracker()
+
+	if ok, err := Login(&throttleUser{"demopass"}, "10.0.0.1"); !ok || err != nil {
+		t.Fatalf("Should have authenticated user, got %v", err)
+	}
+	if count, _, _ := Tracker.Failures(addressKey("10.0.0.1")); count != 0 {
+		t.Errorf("Should have taken back the attempt of the address, got %d", count)
+	}
+}
+
+func TestLoginUnknown(t *testing.T) {
+	Tracker = NewMemoryTracker()
+
+	if err := LoginUnknown(&throttleUser{"demopass"}, "10.0.0.1"); err != ErrInvalidSecret {
+		t.Fatalf("Should have failed like a wrong secret, got %v", err)
+	}
+	if count, _, _ := Tracker.Failures(addressKey("10.0.0.1")); count != 1 {
+		t.Errorf("Should have recorded a failure of the address, got %d", count)
+	}
+	if _, err := Login(&throttleUser{"demopass"}, "10.0.0.1"); err == nil {
+		t.Error("Should have throttled the next attempt of the address")
+	}
+}
+
+func TestAddressLockout(t *testing.T) {
+	Tracker = NewMemoryTracker()
+	defer func(delay time.Duration) { throttleDelay = delay }(throttleDelay)
+	throttleDelay = 0
+
+	now := time.Now()
+	for i := 0; i < ipLockoutFailures; i++ {
+		Tracker.AddFailure(addressKey("10.0.0.1"), now, time.Hour)
+	}
+	if _, err := Login(&throttleUser{"demopass"}, "10.0.0.1"); err == nil {
+		t.Fatal("Should have locked the address out")
+	}
+	if ok, err := Login(&throttleUser{"demopass"}, "10.0.0.2"); !ok || err != nil {
+		t.Fatalf("Should have authenticated from another address, got %v", err)
+	}
+
+	if err := UnlockAddress("10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := Login(&throttleUser{"demopass"}, "10.0.0.1"); !ok || err != nil {
+		t.Fatalf("Should have authenticated from the unlocked address, got %v", err)
+	}
+}
+
+func TestMemoryTrackerWindow(t *testing.T) {
+	tracker := NewMemoryTracker()
+	now := time.Now()
+	tracker.AddFailure("user:demo", now.Add(-2*time.Hour), time.Hour)
+	if count, _ := tracker.AddFailure("user:demo", now, time.Hour); count != 1 {
+		t.Errorf("Should have forgotten failures older than the window, got %d", count)
+	}
+	if count, _ := tracker.AddFailure("user:demo", now, time.Hour); count != 2 {
+		t.Errorf("Should have counted failures within the window, got %d", count)
+	}
+}
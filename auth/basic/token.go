@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// Token purposes, a token issued for one purpose is refused for another.
+const (
+	PurposeReset  = "reset"  // password reset
+	PurposeVerify = "verify" // email verification
+)
+
+var (
+	ErrInvalidToken = errors.New("auth: invalid token")
+	ErrExpiredToken = errors.New("auth: expired token")
+	ErrUsedToken    = errors.New("auth: token already used")
+)
+
+// TokenStore is implemented by a StorageDriver able to track used tokens, as required by VerifyToken.
+type TokenStore interface {
+	// UseToken marks a token used and returns false if it was used before.
+	// The token id may be forgotten once expires has passed.
+	UseToken(id string, expires time.Time) (bool, error)
+}
+
+// TokenKey signs the tokens, it is set from the `auth.token.key` or `app.secret` setting at startup.
+var TokenKey []byte
+
+func init() {
+	revel.OnAppStart(func() {
+		TokenKey = []byte(revel.Config.StringDefault("auth.token.key", revel.Config.StringDefault("app.secret", "")))
+	})
+}
+
+// IssueToken returns a token for the user, valid for ttl, to be sent by the app in a link,
+// e.g. by email. The token is signed with the HashedSecret() of the user, so it stops working
+// as soon as the password changes.
+func IssueToken(user UserAuth, purpose string, ttl time.Duration) (string, error) {
+	if len(TokenKey) == 0 {
+		return "", errors.New("auth: no token key, set auth.token.key or app.secret")
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	payload := strings.Join([]string{
+		purpose,
+		strconv.FormatInt(time.Now().Add(ttl).Unix(), 10),
+		hex.EncodeToString(nonce),
+		user.UserId(),
+	}, "|")
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(signToken(payload, user.HashedSecret())), nil
+}
+
+// VerifyToken checks a token issued for the purpose and returns its user, loaded through auth.NewUser and the Store.
+// A token is accepted once, the Store must implement TokenStore.
+func VerifyToken(token, purpose string) (UserAuth, error) {
+	tokenStore, ok := Store.(TokenStore)
+	if !ok {
+		return nil, errors.New("auth module StorageDriver does not implement TokenStore")
+	}
+	if NewUser == nil {
+		return nil, errors.New("auth module NewUser not set")
+	}
+	if len(TokenKey) == 0 {
+		return nil, errors.New("auth: no token key, set auth.token.key or app.secret")
+	}
+
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidToken
+	}
+	payload, err1 := base64.RawURLEncoding.DecodeString(parts[0])
+	signature, err2 := base64.RawURLEncoding.DecodeString(parts[1])
+	fields := strings.SplitN(string(payload), "|", 4)
+	if err1 != nil || err2 != nil || len(fields) != 4 || fields[0] != purpose {
+		return nil, ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	user := NewUser(fields[3], "")
+	if err := Store.Load(user); err != nil {
+		if err == ErrUserNotFound {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !hmac.Equal(signature, signToken(string(payload), user.HashedSecret())) {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() > expires {
+		return nil, ErrExpiredToken
+	}
+
+	fresh, err := tokenStore.UseToken(fields[0]+":"+fields[2], time.Unix(expires, 0))
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, ErrUsedToken
+	}
+	return user, nil
+}
+
+func signToken(payload, hashedSecret string) []byte {
+	mac := hmac.New(sha256.New, TokenKey)
+	mac.Write([]byte(payload))
+	mac.Write([]byte{0})
+	mac.Write([]byte(hashedSecret))
+	return mac.Sum(nil)
+}
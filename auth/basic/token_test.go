@@ -0,0 +1,54 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	memoryauth "github.com/revel/modules/auth/basic/driver/storage/memory"
+)
+
+func TestTokens(t *testing.T) {
+	auth.Store = memoryauth.NewMemoryAuthDriver()
+	auth.NewUser = func(userId, secret string) auth.UserAuth { return NewUser(userId, secret) }
+	auth.TokenKey = []byte("test key")
+	defer func() { auth.NewUser, auth.TokenKey = nil, nil }()
+
+	u := NewUser("demo@domain.com", "demopass")
+	if err := auth.Store.Save(u); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := auth.IssueToken(u, auth.PurposeReset, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyToken(token, auth.PurposeVerify); err != auth.ErrInvalidToken {
+		t.Errorf("Should have refused a token issued for another purpose, got %v", err)
+	}
+	if _, err := auth.VerifyToken(token[:len(token)-2]+"xx", auth.PurposeReset); err != auth.ErrInvalidToken {
+		t.Errorf("Should have refused a tampered token, got %v", err)
+	}
+	user, err := auth.VerifyToken(token, auth.PurposeReset)
+	if err != nil || user.UserId() != "demo@domain.com" {
+		t.Fatalf("Should have accepted the token, got %v %v", user, err)
+	}
+	if _, err := auth.VerifyToken(token, auth.PurposeReset); err != auth.ErrUsedToken {
+		t.Errorf("Should have refused a used token, got %v", err)
+	}
+
+	expired, _ := auth.IssueToken(u, auth.PurposeReset, -time.Minute)
+	if _, err := auth.VerifyToken(expired, auth.PurposeReset); err != auth.ErrExpiredToken {
+		t.Errorf("Should have refused an expired token, got %v", err)
+	}
+
+	// changing the password kills the tokens issued before
+	token, _ = auth.IssueToken(u, auth.PurposeReset, time.Hour)
+	changed := NewUser("demo@domain.com", "new-password")
+	if err := auth.Store.Save(changed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := auth.VerifyToken(token, auth.PurposeReset); err != auth.ErrInvalidToken {
+		t.Errorf("Should have refused a token issued before the password changed, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package twofactor
+
+import (
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+)
+
+// Settings of the second factor, read from app.conf (see the auth/basic README).
+var (
+	issuer        = ""
+	skew          = 1
+	verifyURL     = "/login/2fa"
+	timeout       = 5 * time.Minute
+	maxFailures   = 5
+	recoveryCodes = 10
+)
+
+func init() {
+	revel.OnAppStart(loadConfig)
+}
+
+func loadConfig() {
+	issuer = revel.Config.StringDefault("auth.totp.issuer", revel.AppName)
+	skew = revel.Config.IntDefault("auth.totp.skew", skew)
+	verifyURL = revel.Config.StringDefault("auth.2fa.url", verifyURL)
+	maxFailures = revel.Config.IntDefault("auth.2fa.failures", maxFailures)
+	recoveryCodes = revel.Config.IntDefault("auth.2fa.recovery.codes", recoveryCodes)
+	timeout = auth.DurationConfig("auth.2fa.timeout", timeout)
+}
@@ -0,0 +1,88 @@
+package twofactor
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+)
+
+// Session keys of a login waiting for the second factor.
+const (
+	pendingKey      = "auth.2fa.pending"
+	pendingSinceKey = "auth.2fa.since"
+)
+
+var allowed auth.ActionSet
+
+// MarkPending records in the session that the user passed the password check and must now enter a code.
+// Until ClearPending is called, PendingFilter keeps the session on the `auth.2fa.url` page.
+func MarkPending(c *revel.Controller, userId string) {
+	c.Session[pendingKey] = userId
+	c.Session[pendingSinceKey] = strconv.FormatInt(time.Now().Unix(), 10)
+}
+
+// PendingUser returns the id of the user waiting for the second factor,
+// or an empty string when there is none or `auth.2fa.timeout` has elapsed.
+func PendingUser(c *revel.Controller) string {
+	userId, _ := c.Session[pendingKey].(string)
+	since, _ := c.Session[pendingSinceKey].(string)
+	if userId == "" {
+		return ""
+	}
+
+	seconds, err := strconv.ParseInt(since, 10, 64)
+	if err != nil || time.Since(time.Unix(seconds, 0)) > timeout {
+		return ""
+	}
+	return userId
+}
+
+// ClearPending removes the pending state, once the code was verified or the login abandoned.
+func ClearPending(c *revel.Controller) {
+	delete(c.Session, pendingKey)
+	delete(c.Session, pendingSinceKey)
+}
+
+// Allow lets pending sessions reach actions, in the form of "ControllerName.ActionName" or "ControllerName.*".
+// The action asking for the code, and usually the logout action, must be allowed.
+func Allow(actions ...string) {
+	for _, action := range actions {
+		allowed.Add(action)
+	}
+}
+
+// PendingFilter keeps sessions waiting for the second factor away from the app:
+// they are redirected to `auth.2fa.url`, JSON requests are answered with 401 Unauthorized.
+// It must come after the revel.SessionFilter and the revel.RouterFilter.
+func PendingFilter(c *revel.Controller, fc []revel.Filter) {
+	if _, pending := c.Session[pendingKey]; !pending {
+		fc[0](c, fc[1:])
+		return
+	}
+	if PendingUser(c) == "" {
+		// the password check timed out, start over
+		ClearPending(c)
+		fc[0](c, fc[1:])
+		return
+	}
+	if allowed.Contains(c.Action) || c.Request.GetPath() == verifyURL {
+		fc[0](c, fc[1:])
+		return
+	}
+
+	if c.Request.Format == "json" || verifyURL == "" {
+		c.Response.Status = http.StatusUnauthorized
+		c.Result = c.RenderError(errors.New("401: Two-factor authentication required"))
+		return
+	}
+	c.Result = c.Redirect(verifyURL)
+}
+
+// VerifyURL returns the `auth.2fa.url` setting.
+func VerifyURL() string {
+	return verifyURL
+}
@@ -0,0 +1,106 @@
+// Package twofactor adds TOTP (RFC 6238) second factor authentication to auth/basic.
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	totpDigits = 6
+	totpPeriod = 30 // seconds
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random TOTP secret, base32 encoded as expected by authenticator apps.
+func GenerateSecret() (string, error) {
+	key := make([]byte, 20)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(key), nil
+}
+
+// Code returns the TOTP code of the secret at the given time.
+func Code(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(at.Unix())/totpPeriod), nil
+}
+
+// Validate returns true if code is the TOTP code of the secret at the given time,
+// or at up to `auth.totp.skew` time steps before or after it.
+func Validate(secret, code string, at time.Time) bool {
+	_, valid := matchStep(secret, code, at)
+	return valid
+}
+
+// matchStep returns the time step of the code like Validate, for VerifyCode to accept each step once.
+func matchStep(secret, code string, at time.Time) (int64, bool) {
+	key, err := decodeSecret(secret)
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	counter := int64(at.Unix()) / totpPeriod
+	matched, valid := int64(0), false
+	for step := counter - int64(skew); step <= counter+int64(skew); step++ {
+		// check every step, so the time taken does not tell which one matched
+		if step >= 0 && hmac.Equal([]byte(code), []byte(hotp(key, uint64(step)))) {
+			matched, valid = step, true
+		}
+	}
+	return matched, valid
+}
+
+// URI returns the otpauth:// URI of the secret, to be shown as a QR code when enabling 2FA.
+// The issuer defaults to the `auth.totp.issuer` setting.
+func URI(issuerName, account, secret string) string {
+	if issuerName == "" {
+		issuerName = issuer
+	}
+
+	label := url.PathEscape(account)
+	if issuerName != "" {
+		label = url.PathEscape(issuerName) + ":" + label
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	if issuerName != "" {
+		query.Set("issuer", issuerName)
+	}
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(secret, " ", "", -1))
+	return base32NoPadding.DecodeString(strings.TrimRight(secret, "="))
+}
+
+// hotp implements HOTP (RFC 4226) with HMAC-SHA1.
+func hotp(key []byte, counter uint64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
@@ -0,0 +1,156 @@
+package twofactor
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/driver/secret"
+)
+
+// User is implemented by app-level user models supporting a second factor.
+type User interface {
+	auth.UserAuth
+	// TOTPSecret returns the base32 TOTP secret of the user, empty when 2FA is not enabled.
+	TOTPSecret() string
+}
+
+// StepStore is implemented by a StorageDriver able to record the last TOTP time step accepted per user,
+// as required by VerifyCode so that a code is accepted once (RFC 6238 section 5.2).
+type StepStore interface {
+	// UseStep records step as the last time step accepted for the user,
+	// and returns false when it is not after the last step recorded.
+	UseStep(userId string, step int64) (bool, error)
+}
+
+// RecoveryStore is implemented by a StorageDriver keeping the hashed recovery codes of the users,
+// as required by GenerateRecoveryCodes and VerifyCode.
+type RecoveryStore interface {
+	// RecoveryCodes returns the hashes of the unused recovery codes of the user.
+	RecoveryCodes(userId string) ([]string, error)
+	// SetRecoveryCodes replaces the recovery codes of the user with the hashes.
+	SetRecoveryCodes(userId string, hashes []string) error
+	// UseRecoveryCode removes a recovery code hash of the user, and returns false when it was removed before.
+	UseRecoveryCode(userId, hash string) (bool, error)
+}
+
+// ErrTooManyAttempts is returned by VerifyCode after `auth.2fa.failures` wrong codes.
+var ErrTooManyAttempts = errors.New("auth: too many wrong two-factor codes")
+
+// Enabled returns true if the user has a second factor to check after the password.
+func Enabled(user auth.UserAuth) bool {
+	u, ok := user.(User)
+	return ok && u.TOTPSecret() != ""
+}
+
+// VerifyCode checks a TOTP code, or a recovery code which is then removed from the RecoveryStore.
+// A TOTP code is refused once it or a later one was accepted, the auth.Store must implement StepStore.
+// Wrong codes are counted by auth.Tracker, once `auth.2fa.failures` is reached ErrTooManyAttempts is returned
+// until `auth.2fa.timeout` has elapsed. The attempt is started with auth.StartAttempt before the code is checked,
+// so parallel guesses cannot get past the limit.
+func VerifyCode(user User, code string) (bool, error) {
+	key := "2fa:" + user.UserId()
+	if err := auth.StartAttempt(key, maxFailures, timeout, timeout); err != nil {
+		if _, ok := err.(*auth.ThrottledError); ok {
+			return false, ErrTooManyAttempts
+		}
+		return false, err
+	}
+
+	ok, err := checkCode(user, code, time.Now())
+	if err != nil {
+		auth.EndAttempt(key, false, timeout)
+		return false, err
+	}
+	if err := auth.EndAttempt(key, !ok, timeout); err != nil || !ok {
+		return false, err
+	}
+	if auth.Tracker == nil {
+		return true, nil
+	}
+	return true, auth.Tracker.Reset(key)
+}
+
+func checkCode(user User, code string, now time.Time) (bool, error) {
+	code = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+	if len(code) == totpDigits {
+		steps, ok := auth.Store.(StepStore)
+		if !ok {
+			return false, errors.New("auth module StorageDriver does not implement twofactor.StepStore")
+		}
+		step, valid := matchStep(user.TOTPSecret(), code, now)
+		if !valid {
+			return false, nil
+		}
+		return steps.UseStep(user.UserId(), step)
+	}
+
+	recovery, err := recoveryStore()
+	if err != nil {
+		return false, err
+	}
+	hashes, err := recovery.RecoveryCodes(user.UserId())
+	if err != nil {
+		return false, err
+	}
+	for _, hash := range hashes {
+		ok, err := secret.Verify(hash, code)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			// false when a concurrent request used the code first
+			return recovery.UseRecoveryCode(user.UserId(), hash)
+		}
+	}
+	return false, nil
+}
+
+// GenerateRecoveryCodes replaces the recovery codes of the user with `auth.2fa.recovery.codes` new ones,
+// saved hashed through the auth.Store, which must implement RecoveryStore.
+// The plain codes are returned to be shown to the user once.
+func GenerateRecoveryCodes(user User) ([]string, error) {
+	recovery, err := recoveryStore()
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodes)
+	hashes := make([]string, recoveryCodes)
+	for i := range codes {
+		code, err := newRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		if hashes[i], err = secret.Hash(code); err != nil {
+			return nil, err
+		}
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	if err := recovery.SetRecoveryCodes(user.UserId(), hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func recoveryStore() (RecoveryStore, error) {
+	recovery, ok := auth.Store.(RecoveryStore)
+	if !ok {
+		return nil, errors.New("auth module StorageDriver does not implement twofactor.RecoveryStore")
+	}
+	return recovery, nil
+}
+
+// newRecoveryCode returns 10 random lower case base32 characters.
+func newRecoveryCode() (string, error) {
+	random := make([]byte, 10)
+	if _, err := io.ReadFull(rand.Reader, random); err != nil {
+		return "", err
+	}
+	return strings.ToLower(base32.StdEncoding.EncodeToString(random))[:10], nil
+}
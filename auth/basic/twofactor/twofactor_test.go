@@ -0,0 +1,252 @@
+package twofactor
+
+import (
+	"encoding/base32"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/modules/auth/basic/driver/secret"
+	"github.com/revel/revel"
+	"github.com/revel/revel/logger"
+	"github.com/revel/revel/session"
+)
+
+// RFC 6238 test secret
+var testSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+type testUser struct {
+	totpSecret string
+
+	secret.MultiAuth
+}
+
+func (u *testUser) UserId() string         { return "demo@domain.com" }
+func (u *testUser) Secret() string         { return "demopass" }
+func (u *testUser) HashedSecret() string   { return "" }
+func (u *testUser) SetHashedSecret(string) {}
+func (u *testUser) TOTPSecret() string     { return u.totpSecret }
+
+type testStore struct {
+	lastStep      map[string]int64
+	recoveryCodes map[string][]string
+}
+
+func newTestStore() testStore {
+	return testStore{lastStep: map[string]int64{}, recoveryCodes: map[string][]string{}}
+}
+
+func (testStore) Save(interface{}) error { return nil }
+func (testStore) Load(interface{}) error { return nil }
+
+func (s testStore) RecoveryCodes(userId string) ([]string, error) {
+	return s.recoveryCodes[userId], nil
+}
+
+func (s testStore) SetRecoveryCodes(userId string, hashes []string) error {
+	s.recoveryCodes[userId] = hashes
+	return nil
+}
+
+func (s testStore) UseRecoveryCode(userId, hash string) (bool, error) {
+	for i, stored := range s.recoveryCodes[userId] {
+		if stored == hash {
+			s.recoveryCodes[userId] = append(s.recoveryCodes[userId][:i:i], s.recoveryCodes[userId][i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s testStore) UseStep(userId string, step int64) (bool, error) {
+	if last, found := s.lastStep[userId]; found && step <= last {
+		return false, nil
+	}
+	s.lastStep[userId] = step
+	return true, nil
+}
+
+func init() {
+	// keep the tests fast
+	secret.DefaultArgon2Params.Memory, secret.DefaultArgon2Params.Time = 1024, 1
+}
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to 6 digits
+	for unix, expected := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		if code, err := Code(testSecret, time.Unix(unix, 0)); code != expected || err != nil {
+			t.Errorf("Code at %d should be %s, got %s %v", unix, expected, code, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, _ := Code(testSecret, now)
+
+	if !Validate(testSecret, code, now) {
+		t.Error("Should have accepted the current code")
+	}
+	if !Validate(testSecret, code, now.Add(totpPeriod*time.Second)) {
+		t.Error("Should have accepted the previous code")
+	}
+	if Validate(testSecret, code, now.Add(2*totpPeriod*time.Second)) {
+		t.Error("Should have refused a code older than the skew")
+	}
+	if Validate(testSecret, "000000", now) || Validate("not base32!", code, now) {
+		t.Error("Should have refused invalid codes")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Revel App", "demo@domain.com", "JBSWY3DPEHPK3PXP")
+	expected := "otpauth://totp/Revel%20App:demo@domain.com?algorithm=SHA1&digits=6&issuer=Revel+App&period=30&secret=JBSWY3DPEHPK3PXP"
+	if uri != expected {
+		t.Errorf("Unexpected URI %s", uri)
+	}
+}
+
+func TestVerifyCode(t *testing.T) {
+	store := newTestStore()
+	auth.Store = store
+	auth.Tracker = auth.NewMemoryTracker()
+	u := &testUser{totpSecret: testSecret}
+
+	codes, err := GenerateRecoveryCodes(u)
+	if err != nil || len(codes) != recoveryCodes || len(store.recoveryCodes[u.UserId()]) != recoveryCodes {
+		t.Fatalf("Should have generated and saved recovery codes, got %v %v", codes, err)
+	}
+
+	current, _ := Code(testSecret, time.Now())
+	if ok, err := VerifyCode(u, current); !ok || err != nil {
+		t.Errorf("Should have accepted the TOTP code: %v", err)
+	}
+	if ok, _ := VerifyCode(u, current); ok {
+		t.Error("Should have refused a used TOTP code")
+	}
+	previous, _ := Code(testSecret, time.Now().Add(-totpPeriod*time.Second))
+	if ok, _ := VerifyCode(u, previous); ok {
+		t.Error("Should have refused a code older than the used one")
+	}
+
+	if ok, err := VerifyCode(u, codes[3]); !ok || err != nil {
+		t.Fatalf("Should have accepted the recovery code: %v", err)
+	}
+	if len(store.recoveryCodes[u.UserId()]) != recoveryCodes-1 {
+		t.Fatalf("Should have removed the used recovery code")
+	}
+	if ok, _ := VerifyCode(u, codes[3]); ok {
+		t.Fatal("Should have refused a used recovery code")
+	}
+
+	for i := 1; i < maxFailures; i++ {
+		if ok, err := VerifyCode(u, "000000"); ok || err != nil {
+			t.Fatalf("Should have refused a wrong code, got %v %v", ok, err)
+		}
+	}
+	current, _ = Code(testSecret, time.Now())
+	if _, err := VerifyCode(u, current); err != ErrTooManyAttempts {
+		t.Fatalf("Should have refused codes after too many failures, got %v", err)
+	}
+}
+
+type slowStore struct {
+	testStore
+	checks *int32
+}
+
+func (s slowStore) RecoveryCodes(userId string) ([]string, error) {
+	atomic.AddInt32(s.checks, 1)
+	time.Sleep(10 * time.Millisecond)
+	return s.testStore.RecoveryCodes(userId)
+}
+
+func TestParallelVerifyCode(t *testing.T) {
+	var checks int32
+	auth.Store = slowStore{newTestStore(), &checks}
+	auth.Tracker = auth.NewMemoryTracker()
+	u := &testUser{totpSecret: testSecret}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4*maxFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			VerifyCode(u, "wrong-code")
+		}()
+	}
+	wg.Wait()
+
+	if checks > int32(maxFailures) {
+		t.Errorf("Should have checked at most %d codes, checked %d", maxFailures, checks)
+	}
+	if _, err := VerifyCode(u, "wrong-code"); err != ErrTooManyAttempts {
+		t.Errorf("Should have refused codes after too many failures, got %v", err)
+	}
+}
+
+func testPendingFilter(t *testing.T, action, accept string, setup func(c *revel.Controller)) *revel.Controller {
+	t.Helper()
+	r, _ := http.NewRequest("GET", "http://www.example.com/admin", nil)
+	r.Header.Set("Accept", accept)
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(r)
+	context.Response.SetResponse(httptest.NewRecorder())
+	c := revel.NewController(context)
+	c.Log = logger.New("module", "test")
+	c.Session = make(session.Session)
+	c.Action = action
+	c.Request.Format = revel.ResolveFormat(c.Request)
+	setup(c)
+
+	filters := []revel.Filter{
+		PendingFilter,
+		func(c *revel.Controller, fc []revel.Filter) {
+			c.Result = c.RenderText("OK.")
+		},
+	}
+	filters[0](c, filters[1:])
+	return c
+}
+
+func TestPendingFilter(t *testing.T) {
+	Allow("Login.TwoFactor")
+	pending := func(c *revel.Controller) { MarkPending(c, "demo@domain.com") }
+
+	c := testPendingFilter(t, "Admin.Index", "text/html", func(c *revel.Controller) {})
+	if _, ok := c.Result.(*revel.RenderTextResult); !ok {
+		t.Fatalf("Should have let sessions without pending login through, got %#v", c.Result)
+	}
+
+	c = testPendingFilter(t, "Admin.Index", "text/html", pending)
+	if _, ok := c.Result.(*revel.RedirectToURLResult); !ok {
+		t.Fatalf("Should have redirected to the code page, got %#v", c.Result)
+	}
+
+	c = testPendingFilter(t, "Admin.Index", "application/json", pending)
+	if c.Response.Status != http.StatusUnauthorized {
+		t.Fatalf("Should have refused JSON requests, got %d", c.Response.Status)
+	}
+
+	c = testPendingFilter(t, "Login.TwoFactor", "text/html", pending)
+	if _, ok := c.Result.(*revel.RenderTextResult); !ok {
+		t.Fatalf("Should have let the allowed action through, got %#v", c.Result)
+	}
+
+	c = testPendingFilter(t, "Admin.Index", "text/html", func(c *revel.Controller) {
+		MarkPending(c, "demo@domain.com")
+		c.Session[pendingSinceKey] = "0"
+	})
+	if _, ok := c.Result.(*revel.RenderTextResult); !ok || PendingUser(c) != "" {
+		t.Fatalf("Should have dropped the timed out pending login, got %#v", c.Result)
+	}
+}
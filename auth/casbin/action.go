@@ -0,0 +1,69 @@
+package casbinauthz
+
+import (
+	"github.com/revel/revel"
+)
+
+// EnforceMode selects the request a module enforces.
+type EnforceMode int
+
+const (
+	// PathMode enforces (subject, URL path, HTTP method), with a model like `r = sub, obj, act`.
+	PathMode EnforceMode = iota
+	// ActionMode enforces (subject, route parameters..., "Controller.Action"), so policies survive URL changes.
+	// With the module Params set to []string{"tenant"}, the model is like `r = sub, dom, obj`.
+	ActionMode
+)
+
+// canViewArgKey is the ViewArgs key holding the permission check of the `can` template function.
+const canViewArgKey = "_casbin_can"
+
+// Add the `can` template function, hiding the controls the user is not allowed to use:
+//
+//	{{if can . "Orders.Delete"}}<button>Delete</button>{{end}}
+//
+// The action is enforced with the subject and the route parameters of the current request,
+// it is always refused when the filter did not run for the request or the module is in PathMode.
+func init() {
+	revel.TemplateFuncs["can"] = func(viewArgs map[string]interface{}, action string) bool {
+		can, ok := viewArgs[canViewArgKey].(func(string) bool)
+		if !ok {
+			revel.AppLog.Warn("casbin: _casbin_can missing from ViewArgs, is the AuthzFilter in the filter chain?")
+			return false
+		}
+		return can(action)
+	}
+}
+
+// Can decides whether the user of the request can run the action, in the form of "ControllerName.ActionName".
+// It requires the module to be in ActionMode.
+func (cm *CasbinModule) Can(c *revel.Controller, action string) bool {
+	if cm.Mode != ActionMode {
+		c.Log.Warn("casbin: Can requires the ActionMode", "action", action)
+		return false
+	}
+
+	subject, err := cm.SubjectOf(c)
+	if err != nil {
+		c.Log.Warn("Failed to resolve the casbin subject", "error", err)
+		return false
+	}
+	return cm.Enforce(cm.request(c, subject, action)...)
+}
+
+// request returns the enforced request of the subject for the action.
+func (cm *CasbinModule) request(c *revel.Controller, subject, action string) []interface{} {
+	if cm.Mode != ActionMode {
+		return []interface{}{subject, c.Request.GetPath(), c.Request.Method}
+	}
+
+	rvals := []interface{}{subject}
+	for _, param := range cm.Params {
+		value := ""
+		if c.Params != nil {
+			value = c.Params.Route.Get(param)
+		}
+		rvals = append(rvals, value)
+	}
+	return append(rvals, action)
+}
@@ -0,0 +1,92 @@
+package casbinauthz
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/casbin/casbin"
+	"github.com/revel/revel"
+)
+
+const actionModel = `
+[request_definition]
+r = sub, dom, obj
+
+[policy_definition]
+p = sub, dom, obj
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && keyMatch(r.obj, p.obj)
+`
+
+func newActionModule() *CasbinModule {
+	e := casbin.NewEnforcer(casbin.NewModel(actionModel))
+	e.AddPolicy("admin", "acme", "Orders.*")
+	e.AddPolicy("clerk", "acme", "Orders.Show")
+	e.AddGroupingPolicy("alice", "admin", "acme")
+	e.AddGroupingPolicy("bob", "clerk", "acme")
+
+	cm := NewCasbinModule(e)
+	cm.Subject = SessionSubject("user")
+	cm.Mode = ActionMode
+	cm.Params = []string{"tenant"}
+	return cm
+}
+
+func newActionController(user, tenant, action string) *revel.Controller {
+	c := newSubjectController("/"+tenant+"/orders/1", nil)
+	c.Session["user"] = user
+	c.Action = action
+	c.Params = &revel.Params{Route: url.Values{"tenant": {tenant}}}
+	return c
+}
+
+func TestActionMode(t *testing.T) {
+	cm := newActionModule()
+
+	for _, test := range []struct {
+		user, tenant, action string
+		status               int
+	}{
+		{"alice", "acme", "Orders.Delete", http.StatusOK},
+		{"bob", "acme", "Orders.Show", http.StatusOK},
+		{"bob", "acme", "Orders.Delete", http.StatusForbidden},
+		{"alice", "initech", "Orders.Show", http.StatusForbidden},
+	} {
+		c := newActionController(test.user, test.tenant, test.action)
+		if status := runAuthz(cm, c); status != test.status {
+			t.Errorf("%s, %s, %s: %d, supposed to be %d", test.user, test.tenant, test.action, status, test.status)
+		}
+	}
+}
+
+func TestCanTemplateFunc(t *testing.T) {
+	cm := newActionModule()
+	can := revel.TemplateFuncs["can"].(func(map[string]interface{}, string) bool)
+
+	c := newActionController("bob", "acme", "Orders.Show")
+	if can(c.ViewArgs, "Orders.Show") {
+		t.Error("can is supposed to refuse actions before the filter ran")
+	}
+	if status := runAuthz(cm, c); status != http.StatusOK {
+		t.Fatalf("bob: %d, supposed to be 200", status)
+	}
+	if !can(c.ViewArgs, "Orders.Show") {
+		t.Error("bob is supposed to be able to show orders")
+	}
+	if can(c.ViewArgs, "Orders.Delete") {
+		t.Error("bob is not supposed to be able to delete orders")
+	}
+
+	cm.Mode = PathMode
+	if cm.Can(c, "Orders.Show") {
+		t.Error("Can is supposed to refuse actions in PathMode")
+	}
+}
@@ -2,6 +2,7 @@ package casbinauthz
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/casbin/casbin/model"
 	"github.com/casbin/casbin/persist"
@@ -9,6 +10,9 @@ import (
 	gormdb "github.com/revel/modules/orm/gorm/app"
 )
 
+// ErrPolicyNotFound is returned when updating a policy rule missing from the storage.
+var ErrPolicyNotFound = errors.New("casbin: policy rule not found")
+
 type Line struct {
 	PType string `gorm:"size:100"`
 	V0    string `gorm:"size:100"`
@@ -19,33 +23,61 @@ type Line struct {
 	V5    string `gorm:"size:100"`
 }
 
+// Filter selects the policy rules loaded by LoadFilteredPolicy.
+// P and G hold the values of the "p" and "g" rule fields by position, empty values match any field value.
+// For instance, with `p = sub, dom, obj, act` and `g = _, _, _`, the rules of the tenant1 domain are:
+//
+//	Filter{P: []string{"", "tenant1"}, G: []string{"", "", "tenant1"}}
+type Filter struct {
+	P []string
+	G []string
+}
+
 // Adapter represents the Gorm adapter for policy storage.
 type Adapter struct {
-	db *gorm.DB
+	db       *gorm.DB
+	table    string
+	filtered bool
 }
 
 // NewAdapter is the constructor for Adapter.
+// It opens the database with the parameters, replacing the gormdb.DB connection,
+// use NewAdapterByDB to share the connection of the app.
 func NewAdapter(params gormdb.DbInfo) *Adapter {
-	a := &Adapter{}
 	gormdb.InitDBWithParameters(params)
-	a.db = gormdb.DB
+	return NewAdapterByDB(gormdb.DB, "")
+}
 
-	return a
+// NewAdapterByDB is the constructor for an Adapter storing the policy in the table of an open database.
+// gormdb.DB is used when db is nil, and the `casbin.table` setting when table is empty.
+func NewAdapterByDB(db *gorm.DB, table string) *Adapter {
+	if db == nil {
+		db = gormdb.DB
+	}
+	if db == nil {
+		panic("casbin: the gorm database is not open")
+	}
+	if table == "" {
+		table = policyTable
+	}
+	if table == "" {
+		table = db.NewScope(&Line{}).TableName()
+	}
+
+	return &Adapter{db: db, table: table}
+}
+
+// lines returns the query on the policy table.
+func (a *Adapter) lines(db *gorm.DB) *gorm.DB {
+	return db.Table(a.table)
 }
 
 func (a *Adapter) createTable() {
-	if a.db.HasTable(&Line{}) {
+	if a.db.HasTable(a.table) {
 		return
 	}
 
-	err := a.db.CreateTable(&Line{}).Error
-	if err != nil {
-		panic(err)
-	}
-}
-
-func (a *Adapter) dropTable() {
-	err := a.db.DropTable(&Line{}).Error
+	err := a.lines(a.db).CreateTable(&Line{}).Error
 	if err != nil {
 		panic(err)
 	}
@@ -78,7 +110,7 @@ func loadPolicyLine(line Line, model model.Model) {
 // LoadPolicy loads policy from database.
 func (a *Adapter) LoadPolicy(model model.Model) error {
 	var lines []Line
-	err := a.db.Find(&lines).Error
+	err := a.lines(a.db).Where("p_type <> ?", versionType).Find(&lines).Error
 	if err != nil {
 		return err
 	}
@@ -87,9 +119,70 @@ func (a *Adapter) LoadPolicy(model model.Model) error {
 		loadPolicyLine(line, model)
 	}
 
+	a.filtered = false
+	return nil
+}
+
+// LoadFilteredPolicy loads the policy rules matching the filter, a Filter or a *Filter.
+// A nil filter loads the whole policy.
+func (a *Adapter) LoadFilteredPolicy(model model.Model, filter interface{}) error {
+	var f *Filter
+	switch filter := filter.(type) {
+	case nil:
+		return a.LoadPolicy(model)
+	case Filter:
+		f = &filter
+	case *Filter:
+		if filter == nil {
+			return a.LoadPolicy(model)
+		}
+		f = filter
+	default:
+		return fmt.Errorf("casbin: invalid filter type %T", filter)
+	}
+
+	for _, sec := range []struct {
+		ptype  string
+		values []string
+	}{{"p", f.P}, {"g", f.G}} {
+		query, err := filterConditions(a.lines(a.db).Where("p_type LIKE ?", sec.ptype+"%"), 0, sec.values)
+		if err != nil {
+			return err
+		}
+
+		var lines []Line
+		if err := query.Find(&lines).Error; err != nil {
+			return err
+		}
+		for _, line := range lines {
+			loadPolicyLine(line, model)
+		}
+	}
+
+	a.filtered = true
 	return nil
 }
 
+// IsFiltered returns true if the loaded policy has been filtered.
+func (a *Adapter) IsFiltered() bool {
+	return a.filtered
+}
+
+// filterConditions restricts the query to the lines whose fields from fieldIndex on match the values,
+// an empty value matches any field value.
+func filterConditions(query *gorm.DB, fieldIndex int, values []string) (*gorm.DB, error) {
+	if fieldIndex < 0 || fieldIndex+len(values) > 6 {
+		return nil, fmt.Errorf("casbin: invalid policy filter on fields %d to %d", fieldIndex, fieldIndex+len(values)-1)
+	}
+
+	for i, value := range values {
+		if value != "" {
+			query = query.Where(fmt.Sprintf("v%d = ?", fieldIndex+i), value)
+		}
+	}
+	return query, nil
+}
+
 func savePolicyLine(ptype string, rule []string) Line {
 	line := Line{}
 
@@ -117,44 +210,89 @@ func savePolicyLine(ptype string, rule []string) Line {
 }
 
 // SavePolicy saves policy to database.
+// The stored policy is replaced within a transaction, so it is kept as is when the save fails.
 func (a *Adapter) SavePolicy(model model.Model) error {
-	a.dropTable()
 	a.createTable()
 
-	for ptype, ast := range model["p"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			err := a.db.Create(&line).Error
-			if err != nil {
-				return err
-			}
-		}
+	tx := a.db.Begin()
+	if err := tx.Error; err != nil {
+		return err
+	}
+	if err := a.savePolicy(tx, model); err != nil {
+		tx.Rollback()
+		return err
 	}
+	return tx.Commit().Error
+}
 
-	for ptype, ast := range model["g"] {
-		for _, rule := range ast.Policy {
-			line := savePolicyLine(ptype, rule)
-			err := a.db.Create(&line).Error
-			if err != nil {
-				return err
+func (a *Adapter) savePolicy(tx *gorm.DB, model model.Model) error {
+	if err := tx.Exec("DELETE FROM "+tx.Dialect().Quote(a.table)+" WHERE p_type <> ?", versionType).Error; err != nil {
+		return err
+	}
+
+	for _, sec := range []string{"p", "g"} {
+		for ptype, ast := range model[sec] {
+			for _, rule := range ast.Policy {
+				line := savePolicyLine(ptype, rule)
+				if err := a.lines(tx).Create(&line).Error; err != nil {
+					return err
+				}
 			}
 		}
 	}
-
 	return nil
 }
 
+// lineConditions matches the line columns exactly, unset values included.
+func lineConditions(line Line) map[string]interface{} {
+	return map[string]interface{}{
+		"p_type": line.PType,
+		"v0":     line.V0,
+		"v1":     line.V1,
+		"v2":     line.V2,
+		"v3":     line.V3,
+		"v4":     line.V4,
+		"v5":     line.V5,
+	}
+}
+
 // AddPolicy adds a policy rule to the storage.
 func (a *Adapter) AddPolicy(sec string, ptype string, rule []string) error {
-	return errors.New("not implemented")
+	line := savePolicyLine(ptype, rule)
+	return a.lines(a.db).Create(&line).Error
 }
 
 // RemovePolicy removes a policy rule from the storage.
 func (a *Adapter) RemovePolicy(sec string, ptype string, rule []string) error {
-	return errors.New("not implemented")
+	line := savePolicyLine(ptype, rule)
+	return a.lines(a.db).Where(lineConditions(line)).Delete(&Line{}).Error
 }
 
 // RemoveFilteredPolicy removes policy rules that match the filter from the storage.
+// The filter values apply to the fields from fieldIndex on, an empty value matches any field value.
 func (a *Adapter) RemoveFilteredPolicy(sec string, ptype string, fieldIndex int, fieldValues ...string) error {
-	return errors.New("not implemented")
+	query, err := filterConditions(a.lines(a.db).Where("p_type = ?", ptype), fieldIndex, fieldValues)
+	if err != nil {
+		return err
+	}
+	return query.Delete(&Line{}).Error
+}
+
+// UpdatePolicy replaces a policy rule of the storage.
+// Casbin v1 enforcers don't call it, update the enforcer policy after it succeeds:
+//
+//	if err := adapter.UpdatePolicy("p", "p", oldRule, newRule); err == nil {
+//		enforcer.LoadPolicy()
+//	}
+func (a *Adapter) UpdatePolicy(sec string, ptype string, oldRule, newRule []string) error {
+	oldLine, newLine := savePolicyLine(ptype, oldRule), savePolicyLine(ptype, newRule)
+
+	result := a.lines(a.db).Where(lineConditions(oldLine)).Updates(lineConditions(newLine))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
 }
@@ -0,0 +1,159 @@
+package casbinauthz
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/casbin/casbin"
+	"github.com/jinzhu/gorm"
+)
+
+func newSQLiteAdapter(t *testing.T) *Adapter {
+	t.Helper()
+	return newSQLiteTableAdapter(t, "")
+}
+
+func newSQLiteTableAdapter(t *testing.T, table string) *Adapter {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", filepath.Join(t.TempDir(), "casbin.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	a := NewAdapterByDB(db, table)
+	a.createTable()
+	return a
+}
+
+func storedLines(t *testing.T, a *Adapter) []string {
+	t.Helper()
+	var lines []Line
+	if err := a.lines(a.db).Find(&lines).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var texts []string
+	for _, line := range lines {
+		texts = append(texts, strings.Join([]string{line.PType, line.V0, line.V1, line.V2}, ","))
+	}
+	sort.Strings(texts)
+	return texts
+}
+
+func TestAdapterIncremental(t *testing.T) {
+	a := newSQLiteAdapter(t)
+	e := casbin.NewEnforcer("authz_model.conf", "authz_policy.csv")
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Fatal(err)
+	}
+
+	e = casbin.NewEnforcer("authz_model.conf", a)
+	if !e.AddPolicy("dave", "/dataset3/*", "GET") {
+		t.Fatal("Failed to add the policy")
+	}
+	if !e.RemovePolicy("alice", "/dataset1/resource1", "POST") {
+		t.Fatal("Failed to remove the policy")
+	}
+	if !e.RemoveFilteredPolicy(0, "bob", "", "GET") {
+		t.Fatal("Failed to remove the filtered policy")
+	}
+	if err := a.UpdatePolicy("p", "p", []string{"bob", "/dataset2/resource1", "*"}, []string{"bob", "/dataset2/resource1", "GET"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.UpdatePolicy("p", "p", []string{"nobody", "/", "GET"}, []string{"nobody", "/", "POST"}); err != ErrPolicyNotFound {
+		t.Errorf("Expected ErrPolicyNotFound, got %v", err)
+	}
+
+	expected := []string{
+		"g,cathy,dataset1_admin,",
+		"p,alice,/dataset1/*,GET",
+		"p,bob,/dataset2/folder1/*,POST",
+		"p,bob,/dataset2/resource1,GET",
+		"p,dataset1_admin,/dataset1/*,*",
+		"p,dave,/dataset3/*,GET",
+	}
+	if lines := storedLines(t, a); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Stored policy %v, supposed to be %v", lines, expected)
+	}
+
+	e = casbin.NewEnforcer("authz_model.conf", a)
+	if !e.Enforce("dave", "/dataset3/item", "GET") || e.Enforce("bob", "/dataset2/resource2", "GET") {
+		t.Error("The reloaded policy doesn't match the stored lines")
+	}
+}
+
+func TestAdapterSavePolicyRollback(t *testing.T) {
+	a := newSQLiteAdapter(t)
+	e := casbin.NewEnforcer("authz_model.conf", "authz_policy.csv")
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Fatal(err)
+	}
+	before := storedLines(t, a)
+
+	a.db.Exec("CREATE TRIGGER refuse_dave BEFORE INSERT ON lines WHEN NEW.v0 = 'dave' BEGIN SELECT RAISE(ABORT, 'refused'); END")
+	e.AddPolicy("dave", "/dataset3/*", "GET")
+	if err := a.SavePolicy(e.GetModel()); err == nil {
+		t.Fatal("Expected the save to fail")
+	}
+	if lines := storedLines(t, a); !reflect.DeepEqual(lines, before) {
+		t.Errorf("Stored policy %v after a failed save, supposed to be %v", lines, before)
+	}
+}
+
+func TestAdapterTable(t *testing.T) {
+	a := newSQLiteTableAdapter(t, "casbin_rules")
+	e := casbin.NewEnforcer("authz_model.conf", "authz_policy.csv")
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !a.db.HasTable("casbin_rules") || a.db.HasTable(&Line{}) {
+		t.Fatal("The policy is not stored in the casbin_rules table")
+	}
+	if lines := storedLines(t, a); len(lines) != 7 {
+		t.Errorf("Stored %d lines, supposed to be 7", len(lines))
+	}
+}
+
+func TestAdapterFilteredPolicy(t *testing.T) {
+	a := newSQLiteAdapter(t)
+	e := casbin.NewEnforcer("authz_model.conf", "authz_policy.csv")
+	if err := a.SavePolicy(e.GetModel()); err != nil {
+		t.Fatal(err)
+	}
+
+	e = casbin.NewEnforcer("authz_model.conf", a)
+	if err := e.LoadFilteredPolicy(Filter{P: []string{"dataset1_admin"}, G: []string{"cathy"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !e.IsFiltered() {
+		t.Error("The policy is not filtered")
+	}
+	if policy := e.GetPolicy(); len(policy) != 1 || policy[0][0] != "dataset1_admin" {
+		t.Errorf("Loaded policy %v, supposed to hold the dataset1_admin rule only", policy)
+	}
+	if !e.Enforce("cathy", "/dataset1/item", "GET") || e.Enforce("alice", "/dataset1/item", "GET") {
+		t.Error("The filtered policy is not enforced")
+	}
+	if err := e.SavePolicy(); err == nil {
+		t.Error("Expected saving a filtered policy to fail")
+	}
+
+	if err := e.LoadFilteredPolicy(&Filter{P: []string{"", "/dataset2/*"}}); err != nil {
+		t.Fatal(err)
+	}
+	if policy := e.GetPolicy(); len(policy) != 0 {
+		t.Errorf("Loaded policy %v, supposed to be empty", policy)
+	}
+	if err := e.LoadFilteredPolicy("bob"); err == nil {
+		t.Error("Expected an invalid filter to fail")
+	}
+
+	if err := e.LoadPolicy(); err != nil || e.IsFiltered() {
+		t.Errorf("Failed to reload the whole policy: %v", err)
+	}
+}
@@ -1,19 +1,34 @@
 package casbinauthz
 
 import (
+	"errors"
 	"net/http"
+	"sync"
 
 	"github.com/casbin/casbin"
 	"github.com/revel/revel"
 )
 
 type CasbinModule struct {
+	// Subject resolves the subject of the request, HTTP basic authentication by default.
+	Subject SubjectResolver
+	// Anonymous is the subject enforced when none is resolved, AnonymousSubject when empty.
+	Anonymous string
+	// Filter is the filter of the policy reloads, the whole policy is reloaded when nil.
+	Filter interface{}
+	// Mode selects the enforced request, PathMode by default.
+	Mode EnforceMode
+	// Params are the names of the route parameters enforced before the action in ActionMode, like "tenant".
+	Params []string
+
+	lock     sync.RWMutex
 	enforcer *casbin.Enforcer
 }
 
 func NewCasbinModule(enforcer *casbin.Enforcer) *CasbinModule {
 	cm := &CasbinModule{}
 	cm.enforcer = enforcer
+	cm.Subject = BasicAuthSubject
 	return cm
 }
 
@@ -22,21 +37,51 @@ func NewCasbinModule(enforcer *casbin.Enforcer) *CasbinModule {
 // Usage:
 //  1) Add `casbin.AuthzFilter` to the app's filters (it must come after the authentication).
 //  2) Init the Casbin enforcer.
+//
+// In ActionMode, the filter must also come after the revel.ParamsFilter.
 func (cm *CasbinModule) AuthzFilter(c *revel.Controller, fc []revel.Filter) {
-	if !CheckPermission(cm.enforcer, c.Request) {
+	user, err := cm.SubjectOf(c)
+	if err != nil {
+		c.Log.Warn("Failed to resolve the casbin subject", "error", err)
+		c.Response.Status = http.StatusUnauthorized
+		c.Result = c.RenderError(errors.New("401: Unauthorized"))
+		return
+	}
+
+	if !cm.Enforce(cm.request(c, user, c.Action)...) {
 		c.Result = c.Forbidden("Access denied by the Authz plugin.")
 		return
 	}
 
+	c.ViewArgs[canViewArgKey] = func(action string) bool { return cm.Can(c, action) }
+
 	fc[0](c, fc[1:])
 }
 
-// GetUserName gets the user name from the request.
-// Currently, only HTTP basic authentication is supported.
-func GetUserName(r *revel.Request) string {
-	req := r.In.GetRaw().(*http.Request)
-	username, _, _ := req.BasicAuth()
-	return username
+// Enforce decides whether the subject can access the object with the action, it is safe during policy reloads.
+func (cm *CasbinModule) Enforce(rvals ...interface{}) bool {
+	cm.lock.RLock()
+	defer cm.lock.RUnlock()
+
+	return cm.enforcer.Enforce(rvals...)
+}
+
+// SubjectOf returns the subject of the request, or the anonymous subject.
+func (cm *CasbinModule) SubjectOf(c *revel.Controller) (string, error) {
+	subject := ""
+	if cm.Subject != nil {
+		var err error
+		if subject, err = cm.Subject(c); err != nil {
+			return "", err
+		}
+	}
+	if subject != "" {
+		return subject, nil
+	}
+	if cm.Anonymous != "" {
+		return cm.Anonymous, nil
+	}
+	return AnonymousSubject, nil
 }
 
 // CheckPermission checks the user/method/path combination from the request.
@@ -44,6 +89,6 @@ func GetUserName(r *revel.Request) string {
 func CheckPermission(e *casbin.Enforcer, r *revel.Request) bool {
 	user := GetUserName(r)
 	method := r.Method
-	path := r.URL.Path
+	path := r.GetPath()
 	return e.Enforce(user, path, method)
 }
@@ -0,0 +1,24 @@
+package casbinauthz
+
+import (
+	"time"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+)
+
+// AnonymousSubject is the subject enforced for requests without a user.
+var AnonymousSubject = "anonymous"
+
+var (
+	policyTable     = ""
+	watcherInterval = 10 * time.Second
+)
+
+func init() {
+	revel.OnAppStart(func() {
+		AnonymousSubject = revel.Config.StringDefault("casbin.anonymous", AnonymousSubject)
+		policyTable = revel.Config.StringDefault("casbin.table", policyTable)
+		watcherInterval = auth.DurationConfig("casbin.watcher.interval", watcherInterval)
+	})
+}
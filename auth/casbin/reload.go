@@ -0,0 +1,117 @@
+package casbinauthz
+
+import (
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/casbin/casbin"
+	"github.com/casbin/casbin/persist"
+	"github.com/revel/revel"
+)
+
+// Reload loads the policy from the adapter again, using the module Filter.
+// The requests are held during the reload, and the previous policy is kept when it fails.
+func (cm *CasbinModule) Reload() error {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	model := cm.enforcer.GetModel()
+	previous := map[string]map[string][][]string{}
+	for _, sec := range []string{"p", "g"} {
+		previous[sec] = map[string][][]string{}
+		for ptype, ast := range model[sec] {
+			previous[sec][ptype] = ast.Policy
+		}
+	}
+
+	var err error
+	if cm.Filter != nil {
+		err = cm.enforcer.LoadFilteredPolicy(cm.Filter)
+	} else {
+		err = cm.enforcer.LoadPolicy()
+	}
+	if err != nil {
+		for sec, policies := range previous {
+			for ptype, policy := range policies {
+				model[sec][ptype].Policy = policy
+			}
+		}
+		cm.enforcer.BuildRoleLinks()
+		return err
+	}
+	return nil
+}
+
+// Update changes the policy with the enforcer, holding the requests until it returns.
+// Use it instead of changing the enforcer directly while the module serves requests:
+//
+//	casbinModule.Update(func(e *casbin.Enforcer) error {
+//		e.AddPolicy("dave", "/dataset3/*", "GET")
+//		return nil
+//	})
+func (cm *CasbinModule) Update(update func(e *casbin.Enforcer) error) error {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	return update(cm.enforcer)
+}
+
+// SetWatcher reloads the policy when the watcher reports a change by another instance.
+// The enforcer notifies the watcher of its own policy changes.
+func (cm *CasbinModule) SetWatcher(watcher persist.Watcher) error {
+	cm.lock.Lock()
+	defer cm.lock.Unlock()
+
+	cm.enforcer.SetWatcher(watcher)
+	// The callback may run while the enforcer notifies the watcher, with the lock held by Update.
+	return watcher.SetUpdateCallback(func(string) { go cm.reload("watcher") })
+}
+
+// ReloadEvery reloads the policy periodically, until stop is called.
+func (cm *CasbinModule) ReloadEvery(interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				cm.reload("timer")
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// ReloadOnSignal reloads the policy when the process receives one of the signals, like syscall.SIGHUP,
+// until stop is called.
+func (cm *CasbinModule) ReloadOnSignal(signals ...os.Signal) (stop func()) {
+	received := make(chan os.Signal, 1)
+	signal.Notify(received, signals...)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-received:
+				cm.reload(sig.String())
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(received)
+		close(done)
+	}
+}
+
+func (cm *CasbinModule) reload(trigger string) {
+	if err := cm.Reload(); err != nil {
+		revel.AppLog.Error("Failed to reload the casbin policy", "trigger", trigger, "error", err)
+		return
+	}
+	revel.AppLog.Debug("Reloaded the casbin policy", "trigger", trigger)
+}
@@ -0,0 +1,85 @@
+package casbinauthz
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	auth "github.com/revel/modules/auth/basic"
+	"github.com/revel/revel"
+)
+
+// SubjectResolver returns the casbin subject of a request.
+// An empty subject with a nil error means the request is anonymous.
+type SubjectResolver func(c *revel.Controller) (string, error)
+
+// FirstSubject returns the first non empty subject of the resolvers.
+func FirstSubject(resolvers ...SubjectResolver) SubjectResolver {
+	return func(c *revel.Controller) (string, error) {
+		for _, resolver := range resolvers {
+			subject, err := resolver(c)
+			if err != nil || subject != "" {
+				return subject, err
+			}
+		}
+		return "", nil
+	}
+}
+
+// BasicAuthSubject returns the user name sent in the `Authorization: Basic` header.
+// The secret is not checked, an authentication filter must run before the authorization.
+func BasicAuthSubject(c *revel.Controller) (string, error) {
+	return GetUserName(c.Request), nil
+}
+
+// SessionSubject returns a resolver reading the subject from the session key.
+func SessionSubject(key string) SubjectResolver {
+	return func(c *revel.Controller) (string, error) {
+		subject, _ := c.Session[key].(string)
+		return subject, nil
+	}
+}
+
+// AuthUserSubject returns the id of the user authenticated by the auth/basic module,
+// either by its HTTPAuth filter or by the session login.
+func AuthUserSubject(c *revel.Controller) (string, error) {
+	if user := auth.CurrentUser(c); user != nil {
+		return user.UserId(), nil
+	}
+	return "", nil
+}
+
+// GetUserName gets the user name sent with HTTP basic authentication.
+func GetUserName(r *revel.Request) string {
+	authorization := r.GetHttpHeader("Authorization")
+	i := strings.Index(authorization, " ")
+	if i == -1 || !strings.EqualFold(authorization[:i], "Basic") {
+		return ""
+	}
+
+	credentials, err := base64.StdEncoding.DecodeString(strings.TrimSpace(authorization[i+1:]))
+	if err != nil {
+		return ""
+	}
+	username := string(credentials)
+	if i := strings.Index(username, ":"); i != -1 {
+		username = username[:i]
+	}
+	return username
+}
+
+func bearerToken(r *revel.Request) (string, error) {
+	authorization := r.GetHttpHeader("Authorization")
+	if authorization == "" {
+		return "", nil
+	}
+	i := strings.Index(authorization, " ")
+	if i == -1 || !strings.EqualFold(authorization[:i], "Bearer") {
+		return "", nil
+	}
+	token := strings.TrimSpace(authorization[i+1:])
+	if token == "" {
+		return "", fmt.Errorf("casbin: empty bearer token")
+	}
+	return token, nil
+}
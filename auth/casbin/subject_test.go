@@ -0,0 +1,131 @@
+package casbinauthz
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin"
+	"github.com/revel/revel"
+	"github.com/revel/revel/logger"
+	"github.com/revel/revel/session"
+)
+
+func newFileModule(t *testing.T) *CasbinModule {
+	t.Helper()
+	e := casbin.NewEnforcer("authz_model.conf", "authz_policy.csv")
+	e.AddPolicy("anonymous", "/public/*", "GET")
+	return NewCasbinModule(e)
+}
+
+func newSubjectController(path string, prepare func(r *http.Request)) *revel.Controller {
+	r, _ := http.NewRequest("GET", path, nil)
+	if prepare != nil {
+		prepare(r)
+	}
+	context := revel.NewGoContext(nil)
+	context.Request.SetRequest(r)
+	context.Response.SetResponse(httptest.NewRecorder())
+	c := revel.NewController(context)
+	c.Log = logger.New("module", "test")
+	c.Session = session.NewSession()
+	return c
+}
+
+func runAuthz(cm *CasbinModule, c *revel.Controller) int {
+	filters := []revel.Filter{
+		cm.AuthzFilter,
+		func(c *revel.Controller, fc []revel.Filter) {
+			c.RenderHTML("OK.")
+		},
+	}
+	filters[0](c, filters[1:])
+	return c.Response.Status
+}
+
+func signJWT(key []byte, claims string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestBasicAuthSubject(t *testing.T) {
+	cm := newFileModule(t)
+
+	c := newSubjectController("/dataset1/resource1", func(r *http.Request) { r.SetBasicAuth("alice", "123") })
+	if status := runAuthz(cm, c); status != http.StatusOK {
+		t.Errorf("alice: %d, supposed to be 200", status)
+	}
+	c = newSubjectController("/dataset1/resource1", func(r *http.Request) { r.SetBasicAuth("bob", "123") })
+	if status := runAuthz(cm, c); status != http.StatusForbidden {
+		t.Errorf("bob: %d, supposed to be 403", status)
+	}
+}
+
+func TestAnonymousSubject(t *testing.T) {
+	cm := newFileModule(t)
+
+	if status := runAuthz(cm, newSubjectController("/public/index", nil)); status != http.StatusOK {
+		t.Errorf("anonymous public: %d, supposed to be 200", status)
+	}
+	if status := runAuthz(cm, newSubjectController("/dataset1/resource1", nil)); status != http.StatusForbidden {
+		t.Errorf("anonymous private: %d, supposed to be 403", status)
+	}
+
+	cm.Anonymous = "guest"
+	if status := runAuthz(cm, newSubjectController("/public/index", nil)); status != http.StatusForbidden {
+		t.Errorf("guest public: %d, supposed to be 403", status)
+	}
+}
+
+func TestSessionSubject(t *testing.T) {
+	cm := newFileModule(t)
+	cm.Subject = SessionSubject("user")
+
+	c := newSubjectController("/dataset1/item", nil)
+	c.Session["user"] = "cathy"
+	if status := runAuthz(cm, c); status != http.StatusOK {
+		t.Errorf("cathy: %d, supposed to be 200", status)
+	}
+}
+
+func TestJWTSubject(t *testing.T) {
+	key := []byte("secret")
+	cm := newFileModule(t)
+	cm.Subject = FirstSubject(JWTSubject("sub", key), BasicAuthSubject)
+
+	bearer := func(token string) func(r *http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+token) }
+	}
+
+	token := signJWT(key, `{"sub":"bob","exp":`+formatUnix(time.Now().Add(time.Hour))+`}`)
+	if status := runAuthz(cm, newSubjectController("/dataset2/resource1", bearer(token))); status != http.StatusOK {
+		t.Errorf("valid token: %d, supposed to be 200", status)
+	}
+
+	token = signJWT([]byte("other"), `{"sub":"bob"}`)
+	if status := runAuthz(cm, newSubjectController("/dataset2/resource1", bearer(token))); status != http.StatusUnauthorized {
+		t.Errorf("wrong key: %d, supposed to be 401", status)
+	}
+
+	token = signJWT(key, `{"sub":"bob","exp":`+formatUnix(time.Now().Add(-time.Hour))+`}`)
+	if status := runAuthz(cm, newSubjectController("/dataset2/resource1", bearer(token))); status != http.StatusUnauthorized {
+		t.Errorf("expired token: %d, supposed to be 401", status)
+	}
+
+	c := newSubjectController("/dataset2/resource1", func(r *http.Request) { r.SetBasicAuth("bob", "123") })
+	if status := runAuthz(cm, c); status != http.StatusOK {
+		t.Errorf("basic fallback: %d, supposed to be 200", status)
+	}
+}
+
+func formatUnix(t time.Time) string {
+	return strconv.FormatInt(t.Unix(), 10)
+}
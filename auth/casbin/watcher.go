@@ -0,0 +1,135 @@
+package casbinauthz
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// versionType is the PType of the Line holding the policy version, in V0.
+const versionType = "_version"
+
+// DBWatcher synchronizes the policy of the app instances sharing the adapter database, without a message broker.
+// Each policy change writes a new version in the policy table, and the other instances poll it.
+type DBWatcher struct {
+	adapter  *Adapter
+	lock     sync.Mutex
+	version  string
+	callback func(string)
+	done     chan struct{}
+	close    sync.Once
+}
+
+// NewDBWatcher is the constructor for DBWatcher, polling the version every interval.
+// The `casbin.watcher.interval` setting is used when interval is zero.
+func NewDBWatcher(adapter *Adapter, interval time.Duration) (*DBWatcher, error) {
+	if interval <= 0 {
+		interval = watcherInterval
+	}
+	adapter.createTable()
+
+	w := &DBWatcher{adapter: adapter, done: make(chan struct{})}
+	version, err := w.currentVersion()
+	if err != nil {
+		return nil, err
+	}
+	w.version = version
+
+	go w.poll(interval)
+	return w, nil
+}
+
+// SetUpdateCallback sets the function called when another instance changed the policy.
+func (w *DBWatcher) SetUpdateCallback(callback func(string)) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.callback = callback
+	return nil
+}
+
+// Update writes a new policy version, for the other instances to reload the policy.
+func (w *DBWatcher) Update() error {
+	version, err := newVersion()
+	if err != nil {
+		return err
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	a := w.adapter
+	result := a.lines(a.db).Where("p_type = ?", versionType).Update("v0", version)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		line := Line{PType: versionType, V0: version}
+		if err := a.lines(a.db).Create(&line).Error; err != nil {
+			return err
+		}
+	}
+	w.version = version
+	return nil
+}
+
+// Close stops polling the version.
+func (w *DBWatcher) Close() {
+	w.close.Do(func() { close(w.done) })
+}
+
+func (w *DBWatcher) poll(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.check()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// check calls the callback when the version changed since the last check or update.
+func (w *DBWatcher) check() {
+	w.lock.Lock()
+	version, err := w.currentVersion()
+	if err != nil {
+		w.lock.Unlock()
+		revel.AppLog.Warn("Failed to read the casbin policy version", "error", err)
+		return
+	}
+	changed := version != w.version
+	w.version = version
+	callback := w.callback
+	w.lock.Unlock()
+
+	if changed && callback != nil {
+		callback(version)
+	}
+}
+
+func (w *DBWatcher) currentVersion() (string, error) {
+	a := w.adapter
+	var versions []string
+	if err := a.lines(a.db).Where("p_type = ?", versionType).Pluck("v0", &versions).Error; err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", nil
+	}
+	return versions[0], nil
+}
+
+func newVersion() (string, error) {
+	version := make([]byte, 16)
+	if _, err := rand.Read(version); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(version), nil
+}
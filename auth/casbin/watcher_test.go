@@ -0,0 +1,93 @@
+package casbinauthz
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin"
+	"github.com/jinzhu/gorm"
+)
+
+func TestDBWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "casbin.db")
+	open := func() *Adapter {
+		db, err := gorm.Open("sqlite3", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		return NewAdapterByDB(db, "")
+	}
+
+	a1, a2 := open(), open()
+	if err := a1.SavePolicy(casbin.NewEnforcer("authz_model.conf", "authz_policy.csv").GetModel()); err != nil {
+		t.Fatal(err)
+	}
+
+	cm1 := NewCasbinModule(casbin.NewEnforcer("authz_model.conf", a1))
+	cm2 := NewCasbinModule(casbin.NewEnforcer("authz_model.conf", a2))
+	for _, cm := range []*CasbinModule{cm1, cm2} {
+		w, err := NewDBWatcher(cm.enforcer.GetAdapter().(*Adapter), 10*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+		if err := cm.SetWatcher(w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if cm2.Enforce("dave", "/dataset3/item", "GET") {
+		t.Fatal("dave is not supposed to have access yet")
+	}
+	cm1.Update(func(e *casbin.Enforcer) error {
+		e.AddPolicy("dave", "/dataset3/*", "GET")
+		return nil
+	})
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !cm2.Enforce("dave", "/dataset3/item", "GET") {
+		if time.Now().After(deadline) {
+			t.Fatal("The other instance didn't reload the policy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The version row is not part of the policy, and survives a full save.
+	if err := cm1.Update(func(e *casbin.Enforcer) error { return e.SavePolicy() }); err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range cm1.enforcer.GetPolicy() {
+		if rule[0] == versionType {
+			t.Errorf("The version row was loaded as a policy rule: %v", rule)
+		}
+	}
+	if version, _ := (&DBWatcher{adapter: a1}).currentVersion(); version == "" {
+		t.Error("The version row was dropped by SavePolicy")
+	}
+}
+
+func TestReloadKeepsPolicyOnFailure(t *testing.T) {
+	a := newSQLiteAdapter(t)
+	if err := a.SavePolicy(casbin.NewEnforcer("authz_model.conf", "authz_policy.csv").GetModel()); err != nil {
+		t.Fatal(err)
+	}
+	cm := NewCasbinModule(casbin.NewEnforcer("authz_model.conf", a))
+
+	a.db.DropTable(a.table)
+	if err := cm.Reload(); err == nil {
+		t.Fatal("Expected the reload to fail")
+	}
+	if !cm.Enforce("alice", "/dataset1/resource1", "GET") || !cm.Enforce("cathy", "/dataset1/item", "DELETE") {
+		t.Error("The policy was not restored after the failed reload")
+	}
+
+	a.createTable()
+	if err := cm.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Enforce("alice", "/dataset1/resource1", "GET") {
+		t.Error("The policy was not reloaded")
+	}
+}
@@ -0,0 +1,48 @@
+package csrf
+
+import (
+	"net/http"
+
+	"github.com/revel/revel"
+)
+
+// readableCookieName is the cookie set by the `/@csrf/token` endpoint, readable by scripts.
+// The default matches the cookie Angular copies into the X-XSRF-TOKEN header.
+var readableCookieName = "XSRF-TOKEN"
+
+// Token returns a masked token for the client, issuing a new token when none is stored or it expired.
+// Use it to hand the token to clients that never render a template.
+func Token(c *revel.Controller) string {
+	if token, ok := c.Args[argsKey].(string); ok {
+		return MaskToken(token)
+	}
+
+	token, issued, found := loadToken(c)
+	if !found || needsRefresh(issued) {
+		token = RefreshToken(c)
+	}
+	return MaskToken(token)
+}
+
+// CheckOrigin validates the Origin header, falling back to the Referer header, against the request URL
+// and the `csrf.trusted.origins`. It returns the failed check, or nil when the request comes from an allowed origin.
+func CheckOrigin(c *revel.Controller) *Failure {
+	source, header, err := requestOrigin(c.Request.GetHttpHeader("Origin"), c.Request.Referer())
+	if err != nil {
+		return &Failure{ReasonMalformedOrigin, "Unable to parse " + header + " header"}
+	}
+	return checkOrigin(getFullRequestURL(c), source, header)
+}
+
+// SetReadableCookie sets the masked token in a cookie readable by scripts, named by `csrf.readable.cookie`.
+// It shares the path, SameSite and Secure settings of the token cookie.
+func SetReadableCookie(c *revel.Controller, maskedToken string) {
+	c.SetCookie(&http.Cookie{
+		Name:     readableCookieName,
+		Value:    maskedToken,
+		Domain:   revel.CookieDomain,
+		Path:     cookiePath,
+		Secure:   cookieSecure,
+		SameSite: cookieSameSite,
+	})
+}
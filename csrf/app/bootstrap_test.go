@@ -0,0 +1,33 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/revel/revel/session"
+)
+
+func TestToken(t *testing.T) {
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/@csrf/token", nil)
+	c := NewTestController(httptest.NewRecorder(), getRequest)
+	c.Session = make(session.Session)
+
+	masked := Token(c)
+	if unmaskToken(masked) != c.Session[sessionKey] {
+		t.Fatal("token should be issued and stored when missing")
+	}
+	if again := Token(c); again == masked || unmaskToken(again) != unmaskToken(masked) {
+		t.Fatal("token should be masked differently on every call")
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/@csrf/token", nil)
+	getRequest.Header.Add("Origin", "http://evil.com")
+	c := NewTestController(httptest.NewRecorder(), getRequest)
+
+	if failure := CheckOrigin(c); failure == nil || failure.Reason != ReasonOriginMismatch {
+		t.Fatalf("request from another origin should fail, got %v", failure)
+	}
+}
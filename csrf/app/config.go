@@ -0,0 +1,84 @@
+package csrf
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// Token storage modes.
+const (
+	// ModeSession keeps the token in the Revel session (default).
+	ModeSession = "session"
+	// ModeDoubleSubmit keeps the token in a dedicated signed cookie, no server side state is required.
+	ModeDoubleSubmit = "doublesubmit"
+)
+
+var (
+	mode = ModeSession
+
+	// Settings for the token cookie used by ModeDoubleSubmit.
+	cookieName     = "REVEL_CSRF"
+	cookieSameSite = http.SameSiteLaxMode
+	cookieSecure   = false
+	cookiePath     = "/"
+)
+
+func init() {
+	revel.OnAppStart(loadConfig)
+}
+
+// loadConfig reads the csrf settings from the app.conf.
+func loadConfig() {
+	mode = strings.ToLower(revel.Config.StringDefault("csrf.mode", ModeSession))
+	if mode != ModeSession && mode != ModeDoubleSubmit {
+		panic(fmt.Sprintf("csrf: unknown csrf.mode \"%v\". Expected \"%v\" or \"%v\".", mode, ModeSession, ModeDoubleSubmit))
+	}
+	if mode == ModeDoubleSubmit {
+		requireSigningKey()
+	}
+
+	cookieName = revel.Config.StringDefault("csrf.cookie.name", revel.CookiePrefix+"_CSRF")
+	cookieSameSite = parseSameSite(revel.Config.StringDefault("csrf.cookie.samesite", "lax"))
+	cookieSecure = revel.Config.BoolDefault("csrf.cookie.secure", revel.CookieSecure)
+	cookiePath = revel.Config.StringDefault("csrf.cookie.path", "/")
+
+	trustedOrigins = parseTrustedOrigins(revel.Config.StringDefault("csrf.trusted.origins", ""))
+
+	var err error
+	if maxAge, err = time.ParseDuration(revel.Config.StringDefault("csrf.token.maxage", "0")); err != nil {
+		panic(fmt.Sprintf("csrf: invalid csrf.token.maxage: %v", err))
+	}
+
+	markExemptList(revel.Config.StringDefault("csrf.exempt", ""))
+
+	formField = revel.Config.StringDefault("csrf.field", "csrftoken")
+	multipartField = revel.Config.StringDefault("csrf.multipart.field", formField)
+	jsonField = revel.Config.StringDefault("csrf.json.field", "csrftoken")
+	headerNames = splitList(revel.Config.StringDefault("csrf.headers", "X-CSRFToken, X-XSRF-TOKEN"))
+	readableCookieName = revel.Config.StringDefault("csrf.readable.cookie", "XSRF-TOKEN")
+}
+
+// requireSigningKey panics when cookies are not signed, which happens when app.secret is empty.
+// The double-submit cookie could be forged without a signature.
+func requireSigningKey() {
+	if revel.Sign(ModeDoubleSubmit) == "" {
+		panic("csrf: csrf.mode=doublesubmit requires app.secret to sign the token cookie")
+	}
+}
+
+func parseSameSite(value string) http.SameSite {
+	switch strings.ToLower(value) {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	case "default":
+		return http.SameSiteDefaultMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	csrf "github.com/revel/modules/csrf/app"
+	"github.com/revel/revel"
+)
+
+// Csrf serves the token to single page apps that never render a server template.
+type Csrf struct {
+	*revel.Controller
+}
+
+// Token returns the current token as JSON and sets it in a cookie readable by scripts.
+// Requests from other origins than the app itself and the `csrf.trusted.origins` are rejected,
+// requests without Origin and Referer headers are answered.
+//
+// Response: {"token": "...", "header": "X-CSRFToken", "field": "csrftoken"}.
+func (c Csrf) Token() revel.Result {
+	if failure := csrf.CheckOrigin(c.Controller); failure != nil && failure.Reason != csrf.ReasonMissingOrigin {
+		c.Log.Warn("CSRF token request rejected", "reason", failure.Reason, "message", failure.Message)
+		return csrf.FailureHandler(c.Controller, failure)
+	}
+
+	token := csrf.Token(c.Controller)
+	csrf.SetReadableCookie(c.Controller, token)
+	c.Response.Out.Header().Set("Cache-Control", "no-store")
+
+	return c.RenderJSON(map[string]string{
+		"token":  token,
+		"header": csrf.HeaderName(),
+		"field":  csrf.FieldName(),
+	})
+}
@@ -4,11 +4,11 @@ import (
 	"crypto/rand"
 	"crypto/subtle"
 	"encoding/hex"
-	"html/template"
 	"io"
 	"math"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/revel/revel"
 )
@@ -35,7 +35,7 @@ func RefreshToken(c *revel.Controller) string {
 	if err != nil {
 		panic(err)
 	}
-	c.Session["csrf_token"] = token
+	storeToken(c, token, time.Now())
 	return token
 }
 
@@ -45,70 +45,91 @@ func RefreshToken(c *revel.Controller) string {
 //  1) Add `csrf.CsrfFilter` to the app's filters (it must come after the revel.SessionFilter).
 //  2) Add CSRF fields to a form with the template tag `{{ csrftoken . }}`.
 // The filter adds a function closure to the `ViewArgs` that can pull out the secret and make the token as-needed,
-// caching the value in the request. Ajax support provided through the `X-CSRFToken` header.
+// caching the value in the request. Ajax support provided through the `X-CSRFToken` and `X-XSRF-TOKEN` headers.
+// The token is read from the form, multipart or JSON body field and the headers named in the `csrf.field`,
+// `csrf.multipart.field`, `csrf.json.field` and `csrf.headers` settings, for every unsafe method.
+//
+// The token is kept in the session by default. Setting `csrf.mode = doublesubmit` keeps it in a signed
+// cookie instead (see the `csrf.cookie.*` settings), so no server side session state is needed.
+// This mode requires `app.secret` to sign the cookie, the app refuses to start without it.
+//
+// Unsafe requests must come from the same origin, checked with the Origin header and falling back to the Referer
+// header, or from one of the origins listed in `csrf.trusted.origins` (e.g. `https://app.example.com, *.example.com`).
+//
+// Tokens older than `csrf.token.maxage` (e.g. `12h`) are re-issued on safe requests and rejected on unsafe ones.
+// Call `csrf.Rotate` after login and logout to issue a new token when the privileges of the client change.
+//
+// Rejected requests are answered by the `csrf.FailureHandler`.
 func CsrfFilter(c *revel.Controller, fc []revel.Filter) {
-	t, foundToken := c.Session["csrf_token"]
-	var token string
-
-	if !foundToken {
+	token, issued, foundToken := loadToken(c)
+	expired := foundToken && tokenExpired(issued)
+	// Expired tokens are re-issued transparently on safe methods, unsafe methods are rejected by validToken
+	if !foundToken || (allowedMethods[c.Request.Method] && needsRefresh(issued)) {
 		token = RefreshToken(c)
-	} else {
-		token = t.(string)
+		expired = false
 	}
 
-	referer, refErr := url.Parse(c.Request.Referer())
-	if refErr != nil {
-		c.Result = c.Forbidden("REVEL CSRF: Unable to fetch referer")
+	source, header, srcErr := requestOrigin(c.Request.GetHttpHeader("Origin"), c.Request.Referer())
+	if srcErr != nil {
+		reject(c, &Failure{ReasonMalformedOrigin, "Unable to parse " + header + " header"})
 		return
 	}
 
 	requestURL := getFullRequestURL(c)
-	isSameOrigin := sameOrigin(requestURL, referer)
+	originFailure := checkOrigin(requestURL, source, header)
 	// If the Request method isn't in the white listed methods
 	if !allowedMethods[c.Request.Method] && !IsExempt(c) {
-		validToken := validToken(token, isSameOrigin, foundToken, c)
+		validToken := validToken(token, originFailure, foundToken, expired, c)
 		c.Log.Info("Validating route for token", "token", token, "wasfound", foundToken, "isvalid", validToken)
 		if !validToken {
-			c.Log.Warn("Invalid CSRF token", "token", token, "wasfound", foundToken)
 			return
 		}
 	}
 
 	fc[0](c, fc[1:])
 
-	// Only add token to ViewArgs if the request is: not AJAX, not missing referer header, and (is same origin, or is an empty referer).
-	if c.Request.GetHttpHeader("X-CSRFToken") == "" && (referer.String() == "" || isSameOrigin) {
-		c.ViewArgs["_csrftoken"] = token
+	// The action may have rotated the token
+	if t, ok := c.Args[argsKey].(string); ok {
+		token = t
+	}
+
+	// Only add token to ViewArgs if the request is: not AJAX, and (is same or trusted origin, or has no origin and referer).
+	if !hasTokenHeader(c) && (source == nil || originFailure == nil) {
+		c.ViewArgs["_csrftoken"] = MaskToken(token)
 	}
 }
 
 // If this call should be checked validate token.
-func validToken(token string, isSameOrigin, foundToken bool, c *revel.Controller) (result bool) {
+// The originFailure is the result of checkOrigin, nil means the origin is allowed.
+// Failures are passed to the FailureHandler.
+func validToken(token string, originFailure *Failure, foundToken, expired bool, c *revel.Controller) (result bool) {
 	// Token wasn't present at all
 	if !foundToken {
-		c.Result = c.Forbidden("REVEL CSRF: Session token missing.")
+		reject(c, &Failure{ReasonMissingSessionToken, "Session token missing."})
 		return
 	}
 
-	// Same origin
-	if !isSameOrigin {
-		c.Result = c.Forbidden("REVEL CSRF: Same origin mismatch.")
+	// Token is older than csrf.token.maxage
+	if expired {
+		reject(c, &Failure{ReasonExpiredToken, "Token expired."})
 		return
 	}
 
-	var requestToken string
-	// First check for token in post data
-	if c.Request.Method == "POST" {
-		requestToken = c.Params.Get("csrftoken")
+	// Same or trusted origin
+	if originFailure != nil {
+		reject(c, originFailure)
+		return
 	}
 
-	// Then check for token in custom headers, as with AJAX
-	if requestToken == "" {
-		requestToken = c.Request.GetHttpHeader("X-CSRFToken")
+	requestToken := requestToken(c)
+
+	// Tokens rendered into pages are masked, unmask them before comparing against the secret
+	if len(requestToken) == 2*len(token) {
+		requestToken = unmaskToken(requestToken)
 	}
 
 	if requestToken == "" || !compareToken(requestToken, token) {
-		c.Result = c.Forbidden("REVEL CSRF: Invalid token.")
+		reject(c, &Failure{ReasonInvalidToken, "Invalid token."})
 		return
 	}
 
@@ -178,15 +199,3 @@ func compareToken(requestToken, token string) bool {
 func sameOrigin(u1, u2 *url.URL) bool {
 	return u1.Scheme == u2.Scheme && u1.Hostname() == u2.Hostname()
 }
-
-// Add a function to the template functions map.
-func init() {
-	revel.TemplateFuncs["csrftoken"] = func(viewArgs map[string]interface{}) template.HTML {
-		if tokenFunc, ok := viewArgs["_csrftoken"]; !ok {
-			panic("REVEL CSRF: _csrftoken missing from ViewArgs.")
-		} else {
-			//nolint:gosec
-			return template.HTML(tokenFunc.(string))
-		}
-	}
-}
@@ -184,3 +184,47 @@ func TestNoTokenInArgsWhenCORs(t *testing.T) {
 		t.Fatal("ViewArgs should not contain token when not same origin")
 	}
 }
+
+func TestMaskedToken(t *testing.T) {
+	token, _ := RandomString(64)
+
+	masked := MaskToken(token)
+	if masked == token || masked == MaskToken(token) {
+		t.Fatal("masked token should differ from the secret and between calls")
+	}
+	if unmaskToken(masked) != token {
+		t.Fatal("unmasked token should equal the secret")
+	}
+}
+
+func TestFormPostWithMaskedToken(t *testing.T) {
+	resp := httptest.NewRecorder()
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	c := NewTestController(resp, getRequest)
+	c.Session = make(session.Session)
+
+	testFilters[0](c, testFilters)
+	masked := c.ViewArgs["_csrftoken"].(string)
+	if masked == c.Session["csrf_token"] {
+		t.Fatal("rendered token should be masked")
+	}
+
+	// make a new request with the rendered token
+	data := url.Values{}
+	data.Set("csrftoken", masked)
+	formPostRequest, _ := http.NewRequest("POST", "http://www.example.com/", bytes.NewBufferString(data.Encode()))
+	formPostRequest.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	formPostRequest.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
+	formPostRequest.Header.Add("Referer", "http://www.example.com/")
+
+	cnew := NewTestController(resp, formPostRequest)
+	// and replace the old request
+	c.Request = cnew.Request
+	c.Result = nil
+
+	testFilters[0](c, testFilters)
+
+	if c.Response.Status == 403 {
+		t.Fatal("form post with masked token should be allowed")
+	}
+}
@@ -2,35 +2,179 @@ package csrf
 
 import (
 	"fmt"
+	"path"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/revel/revel"
 )
 
+// methodSet holds the HTTP methods an exemption applies to, an empty set applies to all methods.
+type methodSet map[string]bool
+
+func (ms methodSet) allows(method string) bool {
+	return len(ms) == 0 || ms[method]
+}
+
+// Exempt can be embedded into a controller to exclude every action of that controller from the CSRF check.
+//
+//	type Webhooks struct {
+//		*revel.Controller
+//		csrf.Exempt
+//	}
+type Exempt struct{}
+
+func (Exempt) csrfExempt() {}
+
+// exemptController is implemented by controllers embedding Exempt.
+type exemptController interface {
+	csrfExempt()
+}
+
 var (
-	exemptPath   = make(map[string]bool)
-	exemptAction = make(map[string]bool)
+	exemptLock   sync.RWMutex
+	exemptPath   = make(map[string]methodSet)
+	exemptAction = make(map[string]methodSet)
 )
 
-func MarkExempt(route string) {
-	if strings.HasPrefix(route, "/") {
-		// e.g. "/controller/action"
-		exemptPath[strings.ToLower(route)] = true
-	} else if routeParts := strings.Split(route, "."); len(routeParts) == 2 {
-		// e.g. "ControllerName.ActionName"
-		exemptAction[route] = true
-	} else {
-		err := fmt.Sprintf("csrf.MarkExempt() received invalid argument \"%v\". Either provide a path prefixed with \"/\" or controller action in the form of \"ControllerName.ActionName\".", route)
-		panic(err)
+// MarkExempt excludes a route from the CSRF check.
+// The route is either a path or a controller action:
+//   - "/controller/action" an exact path, compared case insensitive
+//   - "/hooks/*" every path below "/hooks/"
+//   - "/hooks/*/event" a pattern using the syntax of path.Match
+//   - "ControllerName.ActionName" a single action, matched against the action the router resolved,
+//     so the exemption follows the routes of the action whatever their path
+//   - "ControllerName.*" every action of the controller
+//
+// When methods are given the exemption only applies to requests using one of them, e.g. MarkExempt("Orders.Update", "PUT").
+// It is safe to call MarkExempt concurrently with requests being served.
+func MarkExempt(route string, methods ...string) {
+	exemptLock.Lock()
+	defer exemptLock.Unlock()
+
+	exemptions, key := exemptionsFor(route)
+	if len(methods) == 0 {
+		exemptions[key] = methodSet{}
+		return
+	}
+
+	ms, found := exemptions[key]
+	if found && len(ms) == 0 {
+		// Already exempt for all methods
+		return
+	}
+	if !found {
+		ms = methodSet{}
+		exemptions[key] = ms
+	}
+	for _, method := range methods {
+		ms[strings.ToUpper(method)] = true
+	}
+}
+
+// Unexempt removes an exemption added by MarkExempt, for all methods.
+func Unexempt(route string) {
+	exemptLock.Lock()
+	defer exemptLock.Unlock()
+
+	exemptions, key := exemptionsFor(route)
+	delete(exemptions, key)
+}
+
+// Exemptions lists the registered exemptions, sorted.
+// Method scoped exemptions are prefixed with the method, e.g. "PUT Orders.Update".
+func Exemptions() (list []string) {
+	exemptLock.RLock()
+	defer exemptLock.RUnlock()
+
+	for _, exemptions := range []map[string]methodSet{exemptPath, exemptAction} {
+		for route, ms := range exemptions {
+			if len(ms) == 0 {
+				list = append(list, route)
+			}
+			for method := range ms {
+				list = append(list, method+" "+route)
+			}
+		}
 	}
+	sort.Strings(list)
+	return
 }
 
+// IsExempt returns true if the request is excluded from the CSRF check, either by MarkExempt
+// or by a controller embedding Exempt.
 func IsExempt(c *revel.Controller) bool {
-	if _, ok := exemptPath[strings.ToLower(c.Request.GetPath())]; ok {
+	if _, ok := c.AppController.(exemptController); ok {
 		return true
-	} else if _, ok := exemptAction[c.Action]; ok {
+	}
+
+	exemptLock.RLock()
+	defer exemptLock.RUnlock()
+
+	method := c.Request.Method
+	requestPath := strings.ToLower(c.Request.GetPath())
+	if ms, ok := exemptPath[requestPath]; ok && ms.allows(method) {
+		return true
+	}
+	if ms, ok := exemptAction[c.Action]; ok && ms.allows(method) {
 		return true
 	}
+	if i := strings.Index(c.Action, "."); i != -1 {
+		if ms, ok := exemptAction[c.Action[:i]+".*"]; ok && ms.allows(method) {
+			return true
+		}
+	}
+
+	for pattern, ms := range exemptPath {
+		if ms.allows(method) && matchPath(pattern, requestPath) {
+			return true
+		}
+	}
 
 	return false
 }
+
+// exemptionsFor validates the route and returns the map and key it is stored under.
+func exemptionsFor(route string) (map[string]methodSet, string) {
+	if strings.HasPrefix(route, "/") {
+		// e.g. "/controller/action" or "/hooks/*"
+		route = strings.ToLower(route)
+		if _, err := path.Match(route, ""); err != nil {
+			panic(fmt.Sprintf("csrf.MarkExempt() received invalid path pattern \"%v\": %v", route, err))
+		}
+		return exemptPath, route
+	} else if routeParts := strings.Split(route, "."); len(routeParts) == 2 {
+		// e.g. "ControllerName.ActionName" or "ControllerName.*"
+		return exemptAction, route
+	}
+
+	err := fmt.Sprintf("csrf.MarkExempt() received invalid argument \"%v\". Either provide a path prefixed with \"/\" or controller action in the form of \"ControllerName.ActionName\".", route)
+	panic(err)
+}
+
+// matchPath matches a lowercase request path against an exempt path pattern.
+func matchPath(pattern, requestPath string) bool {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(requestPath, pattern[:len(pattern)-1])
+	}
+	matched, _ := path.Match(pattern, requestPath)
+	return matched
+}
+
+// markExemptList registers the exemptions of the `csrf.exempt` setting,
+// a comma separated list of routes optionally prefixed by a method, e.g. "/hooks/*, PUT Orders.Update".
+func markExemptList(list string) {
+	for _, entry := range strings.Split(list, ",") {
+		fields := strings.Fields(entry)
+		switch len(fields) {
+		case 0:
+		case 1:
+			MarkExempt(fields[0])
+		case 2:
+			MarkExempt(fields[1], fields[0])
+		default:
+			panic(fmt.Sprintf("csrf: invalid csrf.exempt entry \"%v\". Expected \"[METHOD] route\".", strings.TrimSpace(entry)))
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package csrf
 
 import (
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/revel/revel"
+	"github.com/revel/revel/model"
 	"github.com/revel/revel/session"
 )
 
@@ -53,3 +59,172 @@ func TestExemptAction(t *testing.T) {
 		t.Fatal("post to csrf exempt action should pass")
 	}
 }
+
+func postTo(t *testing.T, method, target, action string) *revel.Controller {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	postRequest, _ := http.NewRequest(method, target, nil)
+	c := NewTestController(resp, postRequest)
+	c.Session = make(session.Session)
+	c.Action = action
+
+	testFilters[0](c, testFilters)
+	return c
+}
+
+func TestExemptPathPattern(t *testing.T) {
+	MarkExempt("/hooks/*")
+	MarkExempt("/api/*/callback")
+	defer Unexempt("/hooks/*")
+	defer Unexempt("/api/*/callback")
+
+	if c := postTo(t, "POST", "http://www.example.com/hooks/github/push", ""); c.Response.Status == 403 {
+		t.Fatal("post below an exempt prefix should pass")
+	}
+	if c := postTo(t, "POST", "http://www.example.com/api/stripe/callback", ""); c.Response.Status == 403 {
+		t.Fatal("post matching an exempt pattern should pass")
+	}
+	if c := postTo(t, "POST", "http://www.example.com/hooksfoo", ""); c.Response.Status != 403 {
+		t.Fatal("post outside an exempt prefix should be forbidden")
+	}
+}
+
+func TestExemptControllerWildcard(t *testing.T) {
+	MarkExempt("Webhooks.*")
+	defer Unexempt("Webhooks.*")
+
+	if c := postTo(t, "POST", "http://www.example.com/wh", "Webhooks.Receive"); c.Response.Status == 403 {
+		t.Fatal("post to an action of an exempt controller should pass")
+	}
+	if c := postTo(t, "POST", "http://www.example.com/wh", "Orders.Receive"); c.Response.Status != 403 {
+		t.Fatal("post to an action of another controller should be forbidden")
+	}
+}
+
+func TestExemptMethod(t *testing.T) {
+	markExemptList("PUT Orders.Update")
+	defer Unexempt("Orders.Update")
+
+	if c := postTo(t, "PUT", "http://www.example.com/orders", "Orders.Update"); c.Response.Status == 403 {
+		t.Fatal("put to a put exempt action should pass")
+	}
+	if c := postTo(t, "POST", "http://www.example.com/orders", "Orders.Update"); c.Response.Status != 403 {
+		t.Fatal("post to a put exempt action should be forbidden")
+	}
+}
+
+func TestExemptions(t *testing.T) {
+	MarkExempt("/list/*", "put", "PATCH")
+	MarkExempt("List.Action")
+	defer Unexempt("/list/*")
+	defer Unexempt("List.Action")
+
+	list := strings.Join(Exemptions(), ",")
+	for _, expected := range []string{"PATCH /list/*", "PUT /list/*", "List.Action"} {
+		if !strings.Contains(list, expected) {
+			t.Errorf("exemptions %q should contain %q", list, expected)
+		}
+	}
+
+	Unexempt("List.Action")
+	if strings.Contains(strings.Join(Exemptions(), ","), "List.Action") {
+		t.Error("unexempt should remove the exemption")
+	}
+}
+
+type exemptTestController struct {
+	*revel.Controller
+	Exempt
+}
+
+func TestExemptController(t *testing.T) {
+	resp := httptest.NewRecorder()
+	postRequest, _ := http.NewRequest("POST", "http://www.example.com/Webhooks/Receive", nil)
+	c := NewTestController(resp, postRequest)
+	c.Session = make(session.Session)
+	c.AppController = &exemptTestController{Controller: c}
+
+	testFilters[0](c, testFilters)
+
+	if c.Response.Status == 403 {
+		t.Fatal("post to a controller embedding csrf.Exempt should pass")
+	}
+}
+
+type csrfHooks struct {
+	*revel.Controller
+}
+
+func (c csrfHooks) Github() revel.Result               { return c.RenderText("OK") }
+func (c csrfHooks) Gitlab() revel.Result               { return c.RenderText("OK") }
+func (c csrfHooks) Receive(source string) revel.Result { return c.RenderText(source) }
+
+func init() {
+	revel.RegisterController((*csrfHooks)(nil), []*revel.MethodType{
+		{Name: "Github"},
+		{Name: "Gitlab"},
+		{Name: "Receive", Args: []*revel.MethodArg{{Name: "source", Type: reflect.TypeOf((*string)(nil))}}},
+	})
+}
+
+// withRoutes installs a router loaded from the routes file content as the revel.MainRouter.
+func withRoutes(t *testing.T, routes string) {
+	t.Helper()
+	routesPath := filepath.Join(t.TempDir(), "routes")
+	if err := ioutil.WriteFile(routesPath, []byte(routes), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if revel.RevelConfig == nil {
+		// Set by revel.Init, the router reads the controller settings
+		revel.RevelConfig = &model.RevelContainer{}
+	}
+	router := revel.NewRouter(routesPath)
+	if err := router.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	mainRouter := revel.MainRouter
+	revel.MainRouter = router
+	t.Cleanup(func() { revel.MainRouter = mainRouter })
+}
+
+func routeTo(t *testing.T, method, target string) *revel.Controller {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	request, _ := http.NewRequest(method, target, nil)
+	c := NewTestController(resp, request)
+	c.Session = make(session.Session)
+	c.Params = &revel.Params{}
+
+	filters := append([]revel.Filter{revel.RouterFilter}, testFilters...)
+	filters[0](c, filters[1:])
+	return c
+}
+
+func TestExemptActionRoute(t *testing.T) {
+	withRoutes(t, `
+POST /hooks/github   csrfHooks.Github
+POST /hooks/gitlab   csrfHooks.Gitlab
+POST /hooks/:source  csrfHooks.Receive
+POST /receive        csrfHooks.Receive("default")
+`)
+	MarkExempt("csrfHooks.Github")
+	defer Unexempt("csrfHooks.Github")
+
+	if c := routeTo(t, "POST", "http://www.example.com/hooks/github"); c.Response.Status == 403 {
+		t.Fatal("post to an exempt action should pass")
+	}
+	if c := routeTo(t, "POST", "http://www.example.com/hooks/gitlab"); c.Response.Status != 403 {
+		t.Fatal("post to an action which is not exempt should be forbidden")
+	}
+
+	MarkExempt("csrfHooks.Receive", "POST")
+	defer Unexempt("csrfHooks.Receive")
+	c := routeTo(t, "POST", "http://www.example.com/receive")
+	if c.Response.Status == 403 {
+		t.Fatal("post to an exempt action with fixed params should pass")
+	}
+	if source := c.Params.Fixed.Get("source"); source != "default" {
+		t.Fatalf("fixed param should be bound to the action argument, got %q", source)
+	}
+}
@@ -0,0 +1,54 @@
+package csrf
+
+import (
+	"net/http"
+
+	"github.com/revel/revel"
+)
+
+// Reason is a machine readable code describing why a request failed the CSRF check,
+// suitable for logs and metrics.
+type Reason string
+
+const (
+	ReasonMalformedOrigin     Reason = "malformed_origin"      // the Origin or Referer header could not be parsed
+	ReasonMissingOrigin       Reason = "missing_origin"        // neither Origin nor Referer header present
+	ReasonOriginMismatch      Reason = "origin_mismatch"       // not the same origin and not a trusted origin
+	ReasonMissingSessionToken Reason = "missing_session_token" // no token stored for the client
+	ReasonExpiredToken        Reason = "expired_token"         // the stored token is older than csrf.token.maxage
+	ReasonInvalidToken        Reason = "invalid_token"         // the request token is missing or does not match
+)
+
+// Failure describes a request rejected by the CSRF check.
+type Failure struct {
+	Reason  Reason
+	Message string
+}
+
+// FailureHandlerFunc builds the result sent to the client when a request fails the CSRF check.
+type FailureHandlerFunc func(c *revel.Controller, failure *Failure) revel.Result
+
+// FailureHandler is called for every request failing the CSRF check.
+// Replace it to customize the response, e.g. to count failures per reason.
+var FailureHandler FailureHandlerFunc = DefaultFailureHandler
+
+// DefaultFailureHandler answers 403 Forbidden, with a JSON body when the client accepts JSON
+// and with the regular error page otherwise.
+func DefaultFailureHandler(c *revel.Controller, failure *Failure) revel.Result {
+	if c.Request.Format == "json" {
+		c.Response.Status = http.StatusForbidden
+		return c.RenderJSON(map[string]string{
+			"error":   "csrf",
+			"reason":  string(failure.Reason),
+			"message": failure.Message,
+		})
+	}
+
+	return c.Forbidden("REVEL CSRF: %s", failure.Message)
+}
+
+// reject logs the failure and sets the result built by the FailureHandler.
+func reject(c *revel.Controller, failure *Failure) {
+	c.Log.Warn("CSRF check failed", "reason", failure.Reason, "message", failure.Message)
+	c.Result = FailureHandler(c, failure)
+}
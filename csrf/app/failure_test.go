@@ -0,0 +1,48 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+func TestJSONFailure(t *testing.T) {
+	postRequest, _ := http.NewRequest("POST", "http://www.example.com/", nil)
+	postRequest.Header.Add("Accept", "application/json")
+	postRequest.Header.Add("Referer", "http://www.example.com/")
+	c := NewTestController(httptest.NewRecorder(), postRequest)
+	c.Session = make(session.Session)
+
+	testFilters[0](c, testFilters)
+
+	if _, ok := c.Result.(revel.RenderJSONResult); !ok || c.Response.Status != 403 {
+		t.Fatalf("json client should get a 403 json result, got %d %#v", c.Response.Status, c.Result)
+	}
+}
+
+func TestFailureHandler(t *testing.T) {
+	var reasons []Reason
+	FailureHandler = func(c *revel.Controller, failure *Failure) revel.Result {
+		reasons = append(reasons, failure.Reason)
+		return DefaultFailureHandler(c, failure)
+	}
+	defer func() { FailureHandler = DefaultFailureHandler }()
+
+	postRequest, _ := http.NewRequest("POST", "http://www.example.com/", nil)
+	postRequest.Header.Add("Referer", "http://evil.com/")
+	c := NewTestController(httptest.NewRecorder(), postRequest)
+	c.Session = make(session.Session)
+	RefreshToken(c)
+
+	testFilters[0](c, testFilters)
+
+	if len(reasons) != 1 || reasons[0] != ReasonOriginMismatch {
+		t.Fatalf("handler should be called with the origin mismatch reason, got %v", reasons)
+	}
+	if c.Response.Status != 403 {
+		t.Fatal("post from another origin should be forbidden")
+	}
+}
@@ -0,0 +1,44 @@
+package csrf
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+)
+
+// MaskToken returns a masked variant of the secret token.
+// A fresh random mask is used on every call, so the value written to a page changes on each response while
+// the stored secret stays stable. This protects the secret against BREACH style compression attacks.
+// The result is the hex encoded mask followed by the hex encoded XOR of the mask and the secret.
+func MaskToken(token string) string {
+	secret, err := hex.DecodeString(token)
+	if err != nil {
+		panic(err)
+	}
+
+	mask := make([]byte, len(secret))
+	if _, err := io.ReadFull(rand.Reader, mask); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(mask) + hex.EncodeToString(xorBytes(mask, secret))
+}
+
+// unmaskToken reverses MaskToken. It returns an empty string if the value is not a masked token.
+func unmaskToken(masked string) string {
+	data, err := hex.DecodeString(masked)
+	if err != nil || len(data)%2 != 0 {
+		return ""
+	}
+
+	half := len(data) / 2
+	return hex.EncodeToString(xorBytes(data[:half], data[half:]))
+}
+
+func xorBytes(a, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
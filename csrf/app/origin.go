@@ -0,0 +1,102 @@
+package csrf
+
+import (
+	"net/url"
+	"strings"
+)
+
+// trustedOrigin is a parsed entry of the `csrf.trusted.origins` list.
+type trustedOrigin struct {
+	scheme   string // empty matches the scheme of the request
+	host     string // without the "*." prefix when wildcard is set
+	port     string // empty matches any port
+	wildcard bool   // matches any subdomain of host
+}
+
+var trustedOrigins []trustedOrigin
+
+// parseTrustedOrigins parses a comma separated list of origins.
+// Entries are exact hosts (`app.example.com`), wildcard hosts (`*.example.com`),
+// optionally prefixed with a scheme (`https://*.example.com`) and followed by a port.
+func parseTrustedOrigins(list string) (origins []trustedOrigin) {
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+
+		origin := trustedOrigin{}
+		if i := strings.Index(entry, "://"); i != -1 {
+			origin.scheme, entry = entry[:i], entry[i+3:]
+		}
+		entry = strings.TrimSuffix(entry, "/")
+		if i := strings.LastIndex(entry, ":"); i != -1 {
+			entry, origin.port = entry[:i], entry[i+1:]
+		}
+		if strings.HasPrefix(entry, "*.") {
+			origin.wildcard = true
+			entry = entry[2:]
+		}
+		origin.host = entry
+
+		origins = append(origins, origin)
+	}
+
+	return
+}
+
+// matches returns true if the source is covered by this trusted origin.
+func (o trustedOrigin) matches(requestURL, source *url.URL) bool {
+	scheme := o.scheme
+	if scheme == "" {
+		scheme = requestURL.Scheme
+	}
+	if source.Scheme != scheme {
+		return false
+	}
+	if o.port != "" && source.Port() != o.port {
+		return false
+	}
+
+	host := strings.ToLower(source.Hostname())
+	if o.wildcard {
+		return strings.HasSuffix(host, "."+o.host)
+	}
+	return host == o.host
+}
+
+// requestOrigin returns the URL the request claims to come from and the header it was read from.
+// The Origin header is used first, falling back to the Referer header. The source is nil when neither is present.
+func requestOrigin(origin, referer string) (source *url.URL, header string, err error) {
+	if origin != "" && origin != "null" {
+		header = "Origin"
+		source, err = url.Parse(origin)
+		return
+	}
+
+	header = "Referer"
+	if referer == "" {
+		return
+	}
+	source, err = url.Parse(referer)
+	return
+}
+
+// checkOrigin validates the source against the request URL and the trusted origins.
+// It returns the failed check, or nil when the source is allowed.
+func checkOrigin(requestURL, source *url.URL, header string) *Failure {
+	if source == nil {
+		return &Failure{ReasonMissingOrigin, "Origin and Referer headers missing."}
+	}
+
+	if sameOrigin(requestURL, source) {
+		return nil
+	}
+	for _, origin := range trustedOrigins {
+		if origin.matches(requestURL, source) {
+			return nil
+		}
+	}
+
+	return &Failure{ReasonOriginMismatch, header + " mismatch, " + source.Scheme + "://" + source.Host + " is not a trusted origin."}
+}
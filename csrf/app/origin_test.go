@@ -0,0 +1,83 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+func postWithOrigin(t *testing.T, target, origin, referer string) *revel.Controller {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	postRequest, _ := http.NewRequest("POST", target, nil)
+	c := NewTestController(resp, postRequest)
+	c.Session = make(session.Session)
+	token := RefreshToken(c)
+
+	postRequest.Header.Add("X-CSRFToken", token)
+	if origin != "" {
+		postRequest.Header.Add("Origin", origin)
+	}
+	if referer != "" {
+		postRequest.Header.Add("Referer", referer)
+	}
+	c.Request = NewTestController(resp, postRequest).Request
+
+	testFilters[0](c, testFilters)
+	return c
+}
+
+func TestOriginHeader(t *testing.T) {
+	c := postWithOrigin(t, "http://www.example.com/", "http://www.example.com", "")
+	if c.Response.Status == 403 {
+		t.Fatal("post with same origin header should be allowed")
+	}
+
+	c = postWithOrigin(t, "http://www.example.com/", "http://evil.com", "http://www.example.com/")
+	if c.Response.Status != 403 {
+		t.Fatal("origin header should take precedence over referer")
+	}
+	if err := c.Result.(revel.ErrorResult).Error; !strings.Contains(err.Error(), "Origin mismatch") {
+		t.Fatalf("failure should report the origin check, got %q", err)
+	}
+}
+
+func TestTrustedOrigins(t *testing.T) {
+	trustedOrigins = parseTrustedOrigins("https://app.example.com, *.example.org")
+	defer func() { trustedOrigins = nil }()
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://app.example.com", true},
+		{"http://app.example.com", false},
+		{"https://other.example.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"http://a.example.org", false},
+		{"https://example.org", false},
+		{"https://evilexample.org", false},
+	}
+
+	requestURL, _ := url.Parse("https://api.example.com/")
+	for _, test := range tests {
+		source, header, _ := requestOrigin(test.origin, "")
+		if allowed := checkOrigin(requestURL, source, header) == nil; allowed != test.allowed {
+			t.Errorf("origin %s: allowed %v, expected %v", test.origin, allowed, test.allowed)
+		}
+	}
+}
+
+func TestMissingOriginAndReferer(t *testing.T) {
+	requestURL, _ := url.Parse("https://api.example.com/")
+	source, header, _ := requestOrigin("null", "")
+	if failure := checkOrigin(requestURL, source, header); failure == nil || failure.Reason != ReasonMissingOrigin {
+		t.Fatalf("failure should report missing headers, got %v", failure)
+	}
+}
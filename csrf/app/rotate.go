@@ -0,0 +1,30 @@
+package csrf
+
+import (
+	"time"
+
+	"github.com/revel/revel"
+)
+
+// maxAge is the lifetime of a token, zero means tokens live as long as their store.
+var maxAge time.Duration
+
+// Rotate replaces the token of the current client with a new one and returns it.
+// Call it whenever the privileges of the client change, e.g. after login or logout,
+// so a token obtained before the change can not be used after it.
+func Rotate(c *revel.Controller) string {
+	token := RefreshToken(c)
+	c.Log.Info("CSRF token rotated")
+	return token
+}
+
+// tokenExpired returns true when the token is older than the `csrf.token.maxage`.
+func tokenExpired(issued time.Time) bool {
+	return maxAge > 0 && !issued.IsZero() && time.Since(issued) > maxAge
+}
+
+// needsRefresh returns true when a token found on a safe request should be re-issued,
+// either because it expired or because it was stored without an issue time.
+func needsRefresh(issued time.Time) bool {
+	return tokenExpired(issued) || (maxAge > 0 && issued.IsZero())
+}
@@ -0,0 +1,84 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+func withMaxAge(t *testing.T, d time.Duration) {
+	t.Helper()
+	maxAge = d
+	t.Cleanup(func() { maxAge = 0 })
+}
+
+func expiredSession(token string) session.Session {
+	s := make(session.Session)
+	s[sessionKey] = token
+	s[sessionIssuedKey] = strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+	return s
+}
+
+func TestExpiredTokenRejected(t *testing.T) {
+	withMaxAge(t, time.Hour)
+	token, _ := RandomString(64)
+
+	postRequest, _ := http.NewRequest("POST", "http://www.example.com/", nil)
+	postRequest.Header.Add("X-CSRFToken", token)
+	postRequest.Header.Add("Referer", "http://www.example.com/")
+	c := NewTestController(httptest.NewRecorder(), postRequest)
+	c.Session = expiredSession(token)
+
+	testFilters[0](c, testFilters)
+
+	if c.Response.Status != 403 {
+		t.Fatal("post with an expired token should be forbidden")
+	}
+	if err := c.Result.(revel.ErrorResult).Error; !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("failure should report the expiry, got %q", err)
+	}
+}
+
+func TestExpiredTokenReissued(t *testing.T) {
+	withMaxAge(t, time.Hour)
+	token, _ := RandomString(64)
+
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	c := NewTestController(httptest.NewRecorder(), getRequest)
+	c.Session = expiredSession(token)
+
+	testFilters[0](c, testFilters)
+
+	if c.Session[sessionKey] == token {
+		t.Fatal("expired token should be re-issued on safe requests")
+	}
+}
+
+func TestRotate(t *testing.T) {
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	c := NewTestController(httptest.NewRecorder(), getRequest)
+	c.Session = make(session.Session)
+	token := RefreshToken(c)
+
+	var rotated string
+	filters := []revel.Filter{
+		CsrfFilter,
+		func(c *revel.Controller, fc []revel.Filter) {
+			rotated = Rotate(c)
+		},
+	}
+	filters[0](c, filters[1:])
+
+	if rotated == token || c.Session[sessionKey] != rotated {
+		t.Fatal("rotate should store a new token")
+	}
+	if unmaskToken(c.ViewArgs["_csrftoken"].(string)) != rotated {
+		t.Fatal("rendered token should be the rotated token")
+	}
+}
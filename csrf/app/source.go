@@ -0,0 +1,88 @@
+package csrf
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/revel/revel"
+)
+
+// Names used to look up the token in a request, see the `csrf.field`, `csrf.multipart.field`,
+// `csrf.json.field` and `csrf.headers` settings.
+var (
+	formField      = "csrftoken"
+	multipartField = "csrftoken"
+	jsonField      = "csrftoken"
+	headerNames    = []string{"X-CSRFToken", "X-XSRF-TOKEN"}
+)
+
+// requestToken returns the token sent with the request.
+// The body is checked first, depending on its content type, then the token headers.
+func requestToken(c *revel.Controller) (token string) {
+	switch c.Request.ContentType {
+	case "application/x-www-form-urlencoded":
+		token = c.Params.Form.Get(formField)
+	case "multipart/form-data":
+		token = c.Params.Form.Get(multipartField)
+	case "application/json", "text/json":
+		token = jsonToken(c.Params.JSON)
+	}
+	if token != "" {
+		return
+	}
+
+	// Then check for token in custom headers, as with AJAX
+	for _, header := range headerNames {
+		if token = c.Request.GetHttpHeader(header); token != "" {
+			return
+		}
+	}
+	return
+}
+
+// hasTokenHeader returns true if the request carries one of the token headers.
+func hasTokenHeader(c *revel.Controller) bool {
+	for _, header := range headerNames {
+		if c.Request.GetHttpHeader(header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonToken reads the token field from a JSON object body.
+func jsonToken(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ""
+	}
+	token, _ := fields[jsonField].(string)
+	return token
+}
+
+// splitList splits a comma separated setting, dropping empty entries.
+func splitList(list string) (entries []string) {
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return
+}
+
+// HeaderName returns the first of the `csrf.headers`, for clients sending the token in a header.
+func HeaderName() string {
+	if len(headerNames) == 0 {
+		return ""
+	}
+	return headerNames[0]
+}
+
+// FieldName returns the `csrf.field`, the form field the token is read from.
+func FieldName() string {
+	return formField
+}
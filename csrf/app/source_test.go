@@ -0,0 +1,92 @@
+package csrf
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+// sendWithToken sends a request with a valid token, the body func builds the request body
+// and content type from the token, the header is set to the token when not empty.
+func sendWithToken(t *testing.T, method string, body func(token string) (*bytes.Buffer, string), header string) *revel.Controller {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	c := NewTestController(resp, getRequest)
+	c.Session = make(session.Session)
+	token := RefreshToken(c)
+
+	data, contentType := &bytes.Buffer{}, ""
+	if body != nil {
+		data, contentType = body(token)
+	}
+	request, _ := http.NewRequest(method, "http://www.example.com/", data)
+	if contentType != "" {
+		request.Header.Add("Content-Type", contentType)
+	}
+	if header != "" {
+		request.Header.Add(header, token)
+	}
+	request.Header.Add("Referer", "http://www.example.com/")
+	c.Request = NewTestController(resp, request).Request
+
+	// The body can only be parsed once, so ParamsFilter must not run twice
+	testFilters[0](c, testFilters[1:])
+	return c
+}
+
+func TestPutFormWithToken(t *testing.T) {
+	c := sendWithToken(t, "PUT", func(token string) (*bytes.Buffer, string) {
+		return bytes.NewBufferString(url.Values{"csrftoken": {token}}.Encode()), "application/x-www-form-urlencoded"
+	}, "")
+	if c.Response.Status == 403 {
+		t.Fatal("put form with token should be allowed")
+	}
+}
+
+func TestJSONWithToken(t *testing.T) {
+	c := sendWithToken(t, "PATCH", func(token string) (*bytes.Buffer, string) {
+		return bytes.NewBufferString(`{"name":"value","csrftoken":"` + token + `"}`), "application/json"
+	}, "")
+	if c.Response.Status == 403 {
+		t.Fatal("json body with token should be allowed")
+	}
+}
+
+func TestMultipartWithToken(t *testing.T) {
+	multipartField = "_token"
+	defer func() { multipartField = "csrftoken" }()
+
+	c := sendWithToken(t, "POST", func(token string) (*bytes.Buffer, string) {
+		data := &bytes.Buffer{}
+		writer := multipart.NewWriter(data)
+		_ = writer.WriteField("_token", token)
+		_ = writer.Close()
+		return data, writer.FormDataContentType()
+	}, "")
+	if c.Response.Status == 403 {
+		t.Fatal("multipart post with token should be allowed")
+	}
+}
+
+func TestXsrfHeaderWithToken(t *testing.T) {
+	c := sendWithToken(t, "DELETE", nil, "X-XSRF-TOKEN")
+	if c.Response.Status == 403 {
+		t.Fatal("delete with angular header token should be allowed")
+	}
+}
+
+func TestJSONWithoutToken(t *testing.T) {
+	c := sendWithToken(t, "POST", func(token string) (*bytes.Buffer, string) {
+		return bytes.NewBufferString(`["` + token + `"]`), "application/json"
+	}, "")
+	if c.Response.Status != 403 {
+		t.Fatal("json body without token field should be forbidden")
+	}
+}
@@ -0,0 +1,92 @@
+package csrf
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/revel/revel"
+)
+
+const (
+	// sessionKey is the session key holding the token in ModeSession.
+	sessionKey = "csrf_token"
+	// sessionIssuedKey is the session key holding the unix time the token was issued at in ModeSession.
+	sessionIssuedKey = "csrf_issued"
+	// argsKey is the controller Args key holding a token issued during the current request.
+	argsKey = "_csrf_token"
+)
+
+// loadToken fetches the secret token and its issue time from the store selected by csrf.mode.
+// The issue time is zero for tokens stored without one.
+func loadToken(c *revel.Controller) (token string, issued time.Time, found bool) {
+	if mode == ModeDoubleSubmit {
+		return loadCookieToken(c)
+	}
+
+	t, found := c.Session[sessionKey]
+	if !found {
+		return
+	}
+	if token, found = t.(string); !found {
+		return
+	}
+	if ts, ok := c.Session[sessionIssuedKey].(string); ok {
+		issued = parseIssued(ts)
+	}
+	return
+}
+
+// storeToken saves the secret token in the store selected by csrf.mode.
+func storeToken(c *revel.Controller, token string, issued time.Time) {
+	ts := strconv.FormatInt(issued.Unix(), 10)
+	c.Args[argsKey] = token
+
+	if mode == ModeDoubleSubmit {
+		c.SetCookie(&http.Cookie{
+			Name:     cookieName,
+			Value:    revel.Sign(token+"-"+ts) + "-" + token + "-" + ts,
+			Domain:   revel.CookieDomain,
+			Path:     cookiePath,
+			HttpOnly: true,
+			Secure:   cookieSecure,
+			SameSite: cookieSameSite,
+		})
+		return
+	}
+
+	c.Session[sessionKey] = token
+	c.Session[sessionIssuedKey] = ts
+}
+
+// loadCookieToken reads the token from the signed csrf cookie.
+// A cookie with a bad signature is treated as missing.
+func loadCookieToken(c *revel.Controller) (token string, issued time.Time, found bool) {
+	cookie, err := c.Request.Cookie(cookieName)
+	if err != nil {
+		return
+	}
+
+	// The cookie value is "signature-token-issued"
+	parts := strings.Split(cookie.GetValue(), "-")
+	if len(parts) != 3 {
+		return
+	}
+
+	sig, token, ts := parts[0], parts[1], parts[2]
+	if !revel.Verify(token+"-"+ts, sig) {
+		c.Log.Warn("CSRF cookie signature mismatch", "cookie", cookieName)
+		return "", issued, false
+	}
+
+	return token, parseIssued(ts), true
+}
+
+func parseIssued(ts string) time.Time {
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(unix, 0)
+}
@@ -0,0 +1,105 @@
+package csrf
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/revel/revel"
+	"github.com/revel/revel/session"
+)
+
+func withDoubleSubmit(t *testing.T) {
+	t.Helper()
+	mode = ModeDoubleSubmit
+	t.Cleanup(func() { mode = ModeSession })
+}
+
+func tokenCookie(t *testing.T, resp *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, cookie := range resp.Result().Cookies() {
+		if cookie.Name == cookieName {
+			return cookie
+		}
+	}
+	t.Fatal("token cookie should be set")
+	return nil
+}
+
+func TestDoubleSubmitTokenInCookie(t *testing.T) {
+	withDoubleSubmit(t)
+
+	resp := httptest.NewRecorder()
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	c := NewTestController(resp, getRequest)
+	c.Session = make(session.Session)
+
+	testFilters[0](c, testFilters)
+
+	if _, ok := c.Session[sessionKey]; ok {
+		t.Fatal("token should not be present in session")
+	}
+	cookie := tokenCookie(t, resp)
+	if !cookie.HttpOnly || cookie.SameSite != http.SameSiteLaxMode || cookie.Path != "/" {
+		t.Fatalf("unexpected cookie attributes %#v", cookie)
+	}
+}
+
+func TestDoubleSubmitHeaderWithToken(t *testing.T) {
+	withDoubleSubmit(t)
+
+	resp := httptest.NewRecorder()
+	getRequest, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	c := NewTestController(resp, getRequest)
+	c.Session = make(session.Session)
+	token := RefreshToken(c)
+	cookie := tokenCookie(t, resp)
+
+	postRequest, _ := http.NewRequest("POST", "http://www.example.com/", nil)
+	postRequest.AddCookie(cookie)
+	postRequest.Header.Add("X-CSRFToken", token)
+	postRequest.Header.Add("Referer", "http://www.example.com/")
+	c = NewTestController(httptest.NewRecorder(), postRequest)
+	c.Session = make(session.Session)
+
+	testFilters[0](c, testFilters)
+
+	if c.Response.Status == 403 {
+		t.Fatal("post with cookie and matching header token should be allowed")
+	}
+}
+
+func TestDoubleSubmitTamperedCookie(t *testing.T) {
+	withDoubleSubmit(t)
+
+	postRequest, _ := http.NewRequest("POST", "http://www.example.com/", nil)
+	postRequest.AddCookie(&http.Cookie{Name: cookieName, Value: "bad-" + strings.Repeat("a", 64)})
+	postRequest.Header.Add("X-CSRFToken", strings.Repeat("a", 64))
+	postRequest.Header.Add("Referer", "http://www.example.com/")
+	c := NewTestController(httptest.NewRecorder(), postRequest)
+	c.Session = make(session.Session)
+
+	testFilters[0](c, testFilters)
+
+	if c.Response.Status != 403 {
+		t.Fatal("post with a cookie carrying a bad signature should be forbidden")
+	}
+}
+
+func TestDoubleSubmitRequiresSigningKey(t *testing.T) {
+	t.Cleanup(func() { revel.SetSecretKey(nil) })
+
+	revel.SetSecretKey(nil)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("an empty app.secret should be refused")
+			}
+		}()
+		requireSigningKey()
+	}()
+
+	revel.SetSecretKey([]byte("secret"))
+	requireSigningKey()
+}
@@ -0,0 +1,46 @@
+package csrf
+
+import (
+	"html/template"
+
+	"github.com/revel/revel"
+)
+
+// viewToken returns the masked token the filter added to the ViewArgs.
+// An empty string is returned when the filter did not run for the request.
+func viewToken(viewArgs map[string]interface{}) string {
+	token, ok := viewArgs["_csrftoken"].(string)
+	if !ok {
+		revel.AppLog.Warn("REVEL CSRF: _csrftoken missing from ViewArgs, is csrf.CsrfFilter in the filter chain?")
+	}
+	return token
+}
+
+// Add the functions to the template functions map.
+// The functions emit the masked token prepared by the filter, so it differs on every response.
+// They render nothing when the filter did not run for the request.
+//   - `{{ csrftoken . }}` the bare token
+//   - `{{ csrffield . }}` a hidden form input holding the token
+//   - `{{ csrfmeta . }}` a `<meta name="csrf-token">` tag, for scripts sending the token in a header
+func init() {
+	revel.TemplateFuncs["csrftoken"] = func(viewArgs map[string]interface{}) template.HTML {
+		//nolint:gosec
+		return template.HTML(template.HTMLEscapeString(viewToken(viewArgs)))
+	}
+	revel.TemplateFuncs["csrffield"] = func(viewArgs map[string]interface{}) template.HTML {
+		token := viewToken(viewArgs)
+		if token == "" {
+			return ""
+		}
+		//nolint:gosec
+		return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(formField) + `" value="` + template.HTMLEscapeString(token) + `">`)
+	}
+	revel.TemplateFuncs["csrfmeta"] = func(viewArgs map[string]interface{}) template.HTML {
+		token := viewToken(viewArgs)
+		if token == "" {
+			return ""
+		}
+		//nolint:gosec
+		return template.HTML(`<meta name="csrf-token" content="` + template.HTMLEscapeString(token) + `">`)
+	}
+}
@@ -0,0 +1,34 @@
+package csrf
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/revel/revel"
+)
+
+func renderFunc(name string, viewArgs map[string]interface{}) template.HTML {
+	return revel.TemplateFuncs[name].(func(map[string]interface{}) template.HTML)(viewArgs)
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	viewArgs := map[string]interface{}{"_csrftoken": "abc123"}
+
+	if html := renderFunc("csrftoken", viewArgs); html != "abc123" {
+		t.Errorf("unexpected csrftoken output %q", html)
+	}
+	if html := renderFunc("csrffield", viewArgs); html != `<input type="hidden" name="csrftoken" value="abc123">` {
+		t.Errorf("unexpected csrffield output %q", html)
+	}
+	if html := renderFunc("csrfmeta", viewArgs); html != `<meta name="csrf-token" content="abc123">` {
+		t.Errorf("unexpected csrfmeta output %q", html)
+	}
+}
+
+func TestTemplateFuncsWithoutFilter(t *testing.T) {
+	for _, name := range []string{"csrftoken", "csrffield", "csrfmeta"} {
+		if html := renderFunc(name, map[string]interface{}{}); html != "" {
+			t.Errorf("%s should render nothing when the filter did not run, got %q", name, html)
+		}
+	}
+}
@@ -17,7 +17,7 @@ require (
 	github.com/myesui/uuid v1.0.0 // indirect
 	github.com/newrelic/go-agent v3.4.0+incompatible
 	github.com/poy/onpar v0.0.0-20200406201722-06f95a1c68e8 // indirect
-	github.com/revel/config v0.21.0 // indirect
+	github.com/revel/config v0.21.0
 	github.com/revel/cron v0.21.0
 	github.com/revel/log15 v2.11.20+incompatible // indirect
 	github.com/revel/pathtree v0.0.0-20140121041023-41257a1839e9 // indirect
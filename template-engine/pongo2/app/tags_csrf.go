@@ -0,0 +1,36 @@
+package pongo2
+
+import (
+	"html/template"
+
+	p2 "github.com/flosch/pongo2"
+	"github.com/revel/revel"
+)
+
+// tagCsrfParser implements the {% csrf_field %} and {% csrf_meta %} tags.
+//
+// The tags render the `csrffield` and `csrfmeta` template functions registered by the csrf module,
+// the module must be enabled and `csrf.CsrfFilter` must be in the filter chain.
+// Example: <form method="POST">{% csrf_field %}...</form>.
+func tagCsrfParser(funcName string) p2.TagParser {
+	return func(doc *p2.Parser, start *p2.Token, arguments *p2.Parser) (p2.INodeTag, *p2.Error) {
+		if arguments.Remaining() > 0 {
+			return nil, arguments.Error("CSRF tags take no arguments.", nil)
+		}
+
+		return &INodeImplied{Exec: func(ctx *p2.ExecutionContext, w p2.TemplateWriter) *p2.Error {
+			render, ok := revel.TemplateFuncs[funcName].(func(map[string]interface{}) template.HTML)
+			if !ok {
+				return ctx.Error("Template function "+funcName+" not found, is the csrf module enabled?", nil)
+			}
+
+			w.WriteString(string(render(getContext())))
+			return nil
+		}}, nil
+	}
+}
+
+func init() {
+	p2.RegisterTag("csrf_field", tagCsrfParser("csrffield"))
+	p2.RegisterTag("csrf_meta", tagCsrfParser("csrfmeta"))
+}